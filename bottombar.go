@@ -1,10 +1,11 @@
 package main
 
 import (
+	"strconv"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 const (
@@ -12,6 +13,7 @@ const (
 	UserID
 	DownloadLocation
 	APIEndpoint
+	NextUnwatched
 )
 
 const (
@@ -20,6 +22,8 @@ const (
 	SetUserId
 	SetDownloadLocation
 	SetApiEndpoint
+	Diagnostics
+	History
 )
 
 type bottombarModel struct {
@@ -30,6 +34,8 @@ type bottombarModel struct {
 	focused       int
 	info          string
 	buttonsActive bool
+	summary       selectionSummary
+	ruleSeries    string
 }
 
 func (m *bottombarModel) InitModel() {
@@ -39,6 +45,8 @@ func (m *bottombarModel) InitModel() {
 		{title: "Set API Endpoint", id: SetApiEndpoint},
 		{title: "Set User ID", id: SetUserId},
 		{title: "Set DownloadLocation", id: SetDownloadLocation},
+		{title: "History", id: History},
+		{title: "Diagnostics", id: Diagnostics},
 	}
 	m.buttonsActive = true
 }
@@ -51,18 +59,24 @@ func (m bottombarModel) Update(msg tea.Msg) (bottombarModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case infoMsg:
 		m.info = msg.info
+	case certificateErrorMsg:
+		m.info = errorStyle.Render(string(msg))
+	case transportErrorMsg:
+		m.info = errorStyle.Render(string(msg))
+	case selectionSummaryMsg:
+		m.summary = selectionSummary(msg)
 	case tea.KeyMsg:
 		if m.input.isActive {
 			return m.callInputUpdate(msg)
 		}
-		switch msg.String() {
-		case "right":
+		switch {
+		case key.Matches(msg, keys.Right):
 			m.focused++
 			if m.focused == len(m.buttons) {
 				m.focused = 0
 			}
 			return m, nil
-		case "left":
+		case key.Matches(msg, keys.Left):
 			m.focused--
 			if m.focused == -1 {
 				m.focused = len(m.buttons) - 1
@@ -86,6 +100,14 @@ func (m bottombarModel) Update(msg tea.Msg) (bottombarModel, tea.Cmd) {
 			m.input = InitInput(APIEndpoint, "API Endpoint", m.config.APIEndpoint, "http://jellyfin")
 			return m, m.input.Init()
 		}
+	case askRuleMsg:
+		m.ruleSeries = msg.id
+		var current string
+		if count, ok := m.config.NextUnwatched[msg.id]; ok {
+			current = strconv.Itoa(count)
+		}
+		m.input = InitInput(NextUnwatched, "Next unwatched episodes of "+msg.name, current, "0 to disable")
+		return m, m.input.Init()
 	case inputDoneMsg:
 		m.buttonsActive = true
 		var shouldReload bool
@@ -104,6 +126,15 @@ func (m bottombarModel) Update(msg tea.Msg) (bottombarModel, tea.Cmd) {
 			}
 			m.config.APIEndpoint = msg.value
 			shouldReload = true
+		case NextUnwatched:
+			count, err := strconv.Atoi(msg.value)
+			if err != nil || count <= 0 {
+				delete(m.config.NextUnwatched, m.ruleSeries)
+			} else {
+				m.config.NextUnwatched[m.ruleSeries] = count
+			}
+			writeConfig(*m.config)
+			return m, applyRulesCmd(m.config)
 		}
 		writeConfig(*m.config)
 		if shouldReload {
@@ -139,6 +170,7 @@ func (m bottombarModel) View() string {
 		view += m.input.View()
 		view += " "
 	}
+	view += m.summary.String() + " "
 	if m.info != "" {
 		view += m.info
 	}
@@ -146,15 +178,6 @@ func (m bottombarModel) View() string {
 	return view
 }
 
-var buttonTextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
-
-var buttonStyle = lipgloss.NewStyle().
-	MarginLeft(1).
-	PaddingRight(1).
-	PaddingLeft(1)
-var notActiveButtonStyle = buttonStyle.Copy().Background(lipgloss.Color("8"))
-var activeButtonStyle = buttonStyle.Copy().Background(lipgloss.Color("4"))
-
 type ButtonId int
 
 type buttonModel struct {
@@ -170,8 +193,7 @@ func (m buttonModel) Init() tea.Cmd {
 func (m buttonModel) Update(msg tea.Msg) (buttonModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "enter":
+		if key.Matches(msg, keys.Press) {
 			return m, m.sendPressed
 		}
 	}
@@ -180,6 +202,12 @@ func (m buttonModel) Update(msg tea.Msg) (buttonModel, tea.Cmd) {
 
 func (m buttonModel) View() string {
 	view := buttonTextStyle.Render(m.title)
+	if theme.Monochrome {
+		if m.active {
+			return buttonStyle.Render("[" + m.title + "]")
+		}
+		return buttonStyle.Render(" " + m.title + " ")
+	}
 	if m.active {
 		view = activeButtonStyle.Render(view)
 	} else {
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
+)
+
+const downloadedId = "jellyfindl-downloaded"
+
+var downloadedFolder = jellyfin.Item{Name: "Downloaded (offline)", Id: downloadedId, IsFolder: true}
+
+const defaultCacheTTL = time.Hour
+
+// cacheSaveDelay gathers the pages fetched in a row into a single write of
+// the cache file.
+const cacheSaveDelay = time.Second
+
+type cacheEntry struct {
+	Items   []jellyfin.Item
+	Total   int
+	Fetched time.Time
+}
+
+// itemCache persists the items fetched for every parent so the browser can be
+// served from disk on launch and when the server is unreachable.
+type itemCache struct {
+	mu       sync.Mutex
+	Endpoint string
+	UserId   string
+	Entries  map[string]cacheEntry
+	ttl      time.Duration
+	offline  bool
+	saving   *time.Timer
+}
+
+func getCacheFilePath() string {
+	cacheFolder, err := os.UserCacheDir()
+	checkError(err)
+
+	return path.Join(cacheFolder, "jellyfindl", "items.json")
+}
+
+func loadCache(config *Config) *itemCache {
+	c := &itemCache{
+		Endpoint: config.APIEndpoint,
+		UserId:   config.UserId,
+		Entries:  make(map[string]cacheEntry),
+		ttl:      config.cacheTTL(),
+		offline:  args.Offline,
+	}
+
+	b, err := os.ReadFile(getCacheFilePath())
+	if err != nil {
+		return c
+	}
+
+	stored := itemCache{}
+	if json.Unmarshal(b, &stored) != nil || stored.Endpoint != c.Endpoint || stored.UserId != c.UserId {
+		return c
+	}
+	if stored.Entries != nil {
+		c.Entries = stored.Entries
+	}
+	return c
+}
+
+func (c *itemCache) save() {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(getCacheFilePath()), os.ModePerm)
+	os.WriteFile(getCacheFilePath(), b, 0644)
+}
+
+// get returns the cached entry of a parent and whether it is still within
+// the TTL.
+func (c *itemCache) get(parentId string) (cacheEntry, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.Entries[parentId]
+	return entry, ok, time.Since(entry.Fetched) < c.ttl
+}
+
+// put stores the items of a parent, the file is written shortly after to
+// batch the pages loaded together.
+func (c *itemCache) put(parentId string, items []jellyfin.Item, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Entries[parentId] = cacheEntry{items, total, time.Now()}
+	if c.saving == nil {
+		c.saving = time.AfterFunc(cacheSaveDelay, c.flush)
+	}
+}
+
+// flush writes the pending changes to the cache file.
+func (c *itemCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.saving == nil {
+		return
+	}
+	c.saving.Stop()
+	c.saving = nil
+	c.save()
+}
+
+func (c *itemCache) findItem(id string) (jellyfin.Item, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.Entries {
+		for _, item := range entry.Items {
+			if item.Id == id {
+				return item, true
+			}
+		}
+	}
+	return jellyfin.Item{}, false
+}
+
+func (c *itemCache) isOffline() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offline
+}
+
+// goOffline switches to offline mode if there is anything to browse.
+func (c *itemCache) goOffline() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.Entries) == 0 {
+		return false
+	}
+	c.offline = true
+	return true
+}
+
+// getOfflineChilds serves a parent from the cache regardless of its age and
+// lists the local downloaded items.
+func getOfflineChilds(parentId string, config *Config) jellyfin.Response {
+	if parentId == downloadedId {
+		var res jellyfin.Response
+		for id, file := range config.Downloaded {
+			item, ok := config.cache.findItem(id)
+			if !ok {
+				item = jellyfin.Item{Id: id, Name: filepath.Base(file)}
+			}
+			res.Items = append(res.Items, item)
+		}
+		res.TotalRecordCount = len(res.Items)
+		return res
+	}
+
+	entry, _, _ := config.cache.get(parentId)
+	res := jellyfin.Response{Items: entry.Items, TotalRecordCount: entry.Total}
+	if parentId == "" {
+		res.Items = append(res.Items, downloadedFolder)
+		res.TotalRecordCount = len(res.Items)
+	}
+	return res
+}
+
+// revalidatedMsg is the first page of a parent fetched again. offline is set
+// when the server became unreachable meanwhile and res comes from the cache.
+type revalidatedMsg struct {
+	parentId string
+	res      jellyfin.Response
+	offline  bool
+}
+
+func revalidateCmd(parentId string, config *Config) tea.Cmd {
+	return func() tea.Msg {
+		if config.cache.isOffline() {
+			return nil
+		}
+		res := getChildsPage(context.Background(), parentId, 0, config)
+		return revalidatedMsg{parentId, res, config.cache.isOffline()}
+	}
+}
+
+func (c *Config) cacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(c.CacheTTL)
+	if err != nil {
+		return defaultCacheTTL
+	}
+	return ttl
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"jellyfindl/jellyfin"
+)
+
+type detailsMsg struct {
+	item jellyfin.Item
+}
+
+func (m *jellyfinViewModel) UpdateDetails() tea.Msg {
+	id := m.highlightedId()
+	if id == "" {
+		return nil
+	}
+	if _, ok := m.details[id]; ok {
+		return nil
+	}
+
+	item, ok := getDetails(m.context(), id, m.config)
+	if !ok {
+		return nil
+	}
+	return detailsMsg{item}
+}
+
+func (m jellyfinViewModel) highlightedId() string {
+	if !isInside(m.lists, m.focused) {
+		return ""
+	}
+	it := m.lists[m.focused].SelectedItem()
+	if it == nil {
+		return ""
+	}
+	return it.(item).id
+}
+
+func (m jellyfinViewModel) detailsWidth() int {
+	if !m.showDetails {
+		return 0
+	}
+	return min(m.width/3, 60)
+}
+
+func (m jellyfinViewModel) detailsView() string {
+	h, _ := docStyle.GetFrameSize()
+	width := m.detailsWidth() - h
+
+	details, ok := m.details[m.highlightedId()]
+	if !ok {
+		return docStyle.Width(width).Render("Loading...")
+	}
+
+	lines := []string{detailTitleStyle.Render(details.Name)}
+
+	var facts []string
+	if details.ProductionYear != 0 {
+		facts = append(facts, strconv.Itoa(details.ProductionYear))
+	}
+	if details.RunTimeTicks != 0 {
+		facts = append(facts, formatRuntime(details.RunTimeTicks))
+	}
+	if details.CommunityRating != 0 {
+		facts = append(facts, fmt.Sprintf("★ %.1f", details.CommunityRating))
+	}
+	if len(facts) != 0 {
+		lines = append(lines, detailMutedStyle.Render(strings.Join(facts, " • ")))
+	}
+
+	if details.Overview != "" {
+		lines = append(lines, "", lipgloss.NewStyle().Width(width).Render(details.Overview))
+	}
+
+	if len(details.MediaSources) != 0 {
+		source := details.MediaSources[0]
+		var video, audio, subtitles []string
+		for _, stream := range source.MediaStreams {
+			switch stream.Type {
+			case "Video":
+				video = append(video, fmt.Sprintf("%dx%d %s", stream.Width, stream.Height, stream.Codec))
+			case "Audio":
+				audio = append(audio, streamTitle(stream))
+			case "Subtitle":
+				subtitles = append(subtitles, streamTitle(stream))
+			}
+		}
+
+		container := source.Container
+		if container == "" {
+			container = details.Container
+		}
+
+		lines = append(lines, "")
+		lines = append(lines, detailLine("Container", container))
+		lines = append(lines, detailLine("Video", strings.Join(video, ", ")))
+		lines = append(lines, detailLine("Audio", strings.Join(audio, ", ")))
+		lines = append(lines, detailLine("Subtitles", strings.Join(subtitles, ", ")))
+		lines = append(lines, detailLine("Size", ByteCountSI(source.Size)))
+	}
+
+	return docStyle.Width(width).Render(lipgloss.NewStyle().Width(width).Render(strings.Join(lines, "\n")))
+}
+
+func detailLine(label, value string) string {
+	if value == "" {
+		value = detailMutedStyle.Render("none")
+	}
+	return detailLabelStyle.Render(label+": ") + value
+}
+
+func streamTitle(stream jellyfin.MediaStream) string {
+	if stream.DisplayTitle != "" {
+		return stream.DisplayTitle
+	}
+	if stream.Language != "" {
+		return stream.Language + " " + stream.Codec
+	}
+	return stream.Codec
+}
+
+func formatRuntime(ticks int64) string {
+	runtime := time.Duration(ticks * 100).Round(time.Minute)
+	hours := int(runtime.Hours())
+	minutes := int(runtime.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", hours, minutes)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type diagnosticsModel struct {
+	config *Config
+}
+
+func (m diagnosticsModel) View() string {
+	opts := m.config.transportOptions()
+	retry := m.config.retryPolicy()
+
+	var proxy string
+	proxyURL, err := opts.ProxyFor(m.config.APIEndpoint)
+	switch {
+	case err != nil:
+		proxy = errorStyle.Render(err.Error())
+	case proxyURL == nil:
+		proxy = "none (direct connection)"
+	case m.config.Proxy != "":
+		proxy = proxyURL.Redacted() + " (configured)"
+	default:
+		proxy = proxyURL.Redacted() + " (from environment)"
+	}
+
+	verification := "system certificates"
+	if opts.TLS.CAFile != "" {
+		verification = "system certificates and " + opts.TLS.CAFile
+	}
+	if opts.TLS.InsecureSkipVerify {
+		verification = errorStyle.Render("disabled")
+	}
+	clientCert := "none"
+	if opts.TLS.CertFile != "" {
+		clientCert = opts.TLS.CertFile
+	}
+
+	headers := make([]string, 0, len(m.config.Headers))
+	for key := range m.config.Headers {
+		headers = append(headers, key)
+	}
+	sort.Strings(headers)
+	basicAuth := "none"
+	if m.config.BasicAuth.Username != "" {
+		basicAuth = m.config.BasicAuth.Username
+	}
+
+	offline := "no"
+	if m.config.cache.isOffline() {
+		offline = "yes"
+	}
+
+	lines := []string{
+		detailTitleStyle.Render("Diagnostics"),
+		"",
+		detailLine("Endpoint", m.config.APIEndpoint),
+		detailLine("User ID", m.config.UserId),
+		detailLine("Device", fmt.Sprintf("%s · %s · jellyfindl %s", m.config.deviceName(), m.config.DeviceId, appVersion())),
+		detailLine("Proxy", proxy),
+		detailLine("Certificate verification", verification),
+		detailLine("Client certificate", clientCert),
+		detailLine("Extra headers", strings.Join(headers, ", ")),
+		detailLine("Basic auth user", basicAuth),
+		detailLine("Timeouts", fmt.Sprintf("connect %s · response header %s · idle read %s · stall %s",
+			opts.ConnectTimeout, opts.ResponseHeaderTimeout, opts.IdleReadTimeout, m.config.stallTimeout())),
+		detailLine("Retry", fmt.Sprintf("%d attempts · backoff %s to %s · status %v · network errors %t",
+			retry.MaxAttempts, retry.BaseDelay, retry.MaxDelay, retry.RetryableStatus, retry.RetryNetworkErrors)),
+		detailLine("Configuration", getConfigFilePath()),
+		detailLine("Cache", fmt.Sprintf("%s · TTL %s", getCacheFilePath(), m.config.cacheTTL())),
+		detailLine("Offline", offline),
+		"",
+		detailMutedStyle.Render("Press " + keys.Back.Help().Key + " to go back"),
+	}
+
+	return lipgloss.NewStyle().Margin(1, 2).Render(strings.Join(lines, "\n"))
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
@@ -8,11 +10,14 @@ import (
 	"time"
 
 	"github.com/cavaliergopher/grab/v3"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"jellyfindl/jellyfin"
 )
 
 type downloadState int
@@ -23,11 +28,9 @@ const (
 	finished
 )
 
-var downloadStarted = lipgloss.NewStyle().Foreground(lipgloss.Color("13")).Render("Downloading....")
-
 type downloadItem struct {
 	title, id                   string
-	jellyfinItem                JellyfinItem
+	jellyfinItem                jellyfin.Item
 	seasonNumber, episodeNumber int
 	downloadStarted             bool
 	downloadCompleted           bool
@@ -35,25 +38,52 @@ type downloadItem struct {
 	spinner                     spinner.Model
 	progress                    progress.Model
 	fail                        string
+	attempts, maxAttempts       int
+	nextRetry                   time.Time
+	stallTimeout                time.Duration
+	lastBytes                   int64
+	lastProgress                time.Time
+	stalled                     bool
+	hook                        string
+	pausedUntil                 time.Time
+	pinned                      bool
+	priority, rank              int
+	paused                      bool
+	partial, size               int64
 }
 
-func (i downloadItem) Title() string { return i.title }
+func (i downloadItem) Title() string { return i.queueMarker() + i.title }
 
 func (i downloadItem) Description() string {
 	if i.fail != "" {
-		return lipgloss.NewStyle().Foreground(lipgloss.Color("160")).Render("❌ " + i.fail)
+		fail := "❌ " + i.fail
+		if !i.nextRetry.IsZero() {
+			fail += fmt.Sprintf(" · retry %d/%d at %s", i.attempts+1, i.maxAttempts, i.nextRetry.Format("15:04:05"))
+		}
+		return errorStyle.Render(fail) + i.hookView()
 	}
 
 	if i.downloadCompleted {
-		return lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render("✔ Downloaded !")
+		return successStyle.Render("✔ Downloaded !") + i.hookView()
 	}
 
 	if !i.downloadStarted {
-		return "Waiting...."
+		switch {
+		case i.paused:
+			return progressStyle.Render("⏸ Paused") + i.partialView()
+		case !i.pausedUntil.IsZero():
+			return progressStyle.Render(pausedView(i.pausedUntil)) + i.partialView()
+		}
+		return "Waiting...." + i.partialView()
+	}
+
+	var attempt string
+	if i.attempts > 1 {
+		attempt = fmt.Sprintf("attempt %d/%d ", i.attempts, i.maxAttempts)
 	}
 
 	if i.resp == nil {
-		return i.spinner.View() + " " + downloadStarted
+		return i.spinner.View() + " " + attempt + progressStyle.Render("Downloading....")
 	}
 
 	var eta string
@@ -64,7 +94,25 @@ func (i downloadItem) Description() string {
 		eta = i.resp.ETA().Sub(time.Now()).Round(time.Second).String()
 	}
 
-	return i.spinner.View() + " " + i.progress.View() + " " + bytesPerSecond + "/s " + eta
+	return i.spinner.View() + " " + attempt + i.progress.View() + " " + bytesPerSecond + "/s " + eta
+}
+
+// partialView tells how much of a stopped download is kept.
+func (i downloadItem) partialView() string {
+	if i.partial == 0 {
+		return ""
+	}
+	if i.size <= 0 {
+		return mutedStyle.Render(" · " + ByteCountSI(i.partial) + " kept")
+	}
+	return mutedStyle.Render(fmt.Sprintf(" · %s of %s kept (%d%%)", ByteCountSI(i.partial), ByteCountSI(i.size), i.partial*100/i.size))
+}
+
+func (i downloadItem) hookView() string {
+	if i.hook == "" {
+		return ""
+	}
+	return mutedStyle.Render(" · " + i.hook)
 }
 
 func (i downloadItem) FilterValue() string { return i.title }
@@ -72,11 +120,16 @@ func (i downloadItem) FilterValue() string { return i.title }
 func (i downloadItem) Update(msg tea.Msg) (downloadItem, tea.Cmd) {
 	switch msg := msg.(type) {
 	case startDownloadingItemMsg:
+		i.hook = ""
+		i.pausedUntil = time.Time{}
+		i.paused = false
 		i.downloadStarted = true
+		i.attempts++
+		i.nextRetry = time.Time{}
 		i.spinner = spinner.NewModel()
 		i.spinner.Spinner = spinner.Moon
-		i.spinner.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-		i.progress = progress.New(progress.WithDefaultGradient())
+		i.spinner.Style = progressStyle
+		i.progress = progress.New(progressOption())
 		return i, tea.Batch(i.spinner.Tick)
 	case downloadStartedMsg:
 		i.fail = ""
@@ -95,9 +148,24 @@ func (i downloadItem) Update(msg tea.Msg) (downloadItem, tea.Cmd) {
 		return i, cmd
 	case tickMsg:
 		if i.resp != nil {
-			cmd := i.progress.SetPercent(i.resp.Progress())
-			return i, cmd
+			cmds := []tea.Cmd{i.progress.SetPercent(i.resp.Progress())}
+			if bytes := i.resp.BytesComplete(); bytes != i.lastBytes || i.lastProgress.IsZero() {
+				i.lastBytes = bytes
+				i.lastProgress = time.Now()
+			} else if i.stallTimeout > 0 && !i.stalled && !i.resp.IsComplete() && time.Since(i.lastProgress) > i.stallTimeout {
+				i.stalled = true
+				resp := i.resp
+				cmds = append(cmds, func() tea.Msg {
+					resp.Cancel()
+					return nil
+				})
+			}
+			return i, tea.Batch(cmds...)
 		}
+	case schedulePausedMsg:
+		i.pausedUntil = msg.until
+	case itemPausedMsg:
+		i.paused = msg.paused
 	case downloadCompletedMsg:
 		i.downloadCompleted = true
 	case downloadFailedMsg:
@@ -131,39 +199,54 @@ type downloadModel struct {
 	list          list.Model
 	info          string
 	width, height int
-	items         map[string]JellyfinItem
+	items         map[string]jellyfin.Item
 	downloading   map[string]*grab.Response
+	paused        bool      // by the schedule
+	pausedUntil   time.Time // the next window
+	queuePaused   bool
+	kept          map[*grab.Response]bool // stopped by a pause or a stall, to resume
 }
 
 func (m *downloadModel) InitModel() {
 	m.info = "Download screen"
 	m.list = *createList(make([]list.Item, 0), false)
+	m.list.KeyMap = keys.listKeyMap(false)
 	m.downloading = make(map[string]*grab.Response)
+	m.kept = make(map[*grab.Response]bool)
 }
 
 func (m downloadModel) Init() tea.Cmd {
-	return tea.Batch(m.filterItems, tickCmd())
+	return tea.Batch(m.filterItems(), tickCmd())
 }
 
 func (m downloadModel) Update(msg tea.Msg) (downloadModel, tea.Cmd) {
 	var cmds []tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "enter":
+		switch {
+		case m.list.SettingFilter():
+		case key.Matches(msg, keys.Toggle):
 			if len(m.list.Items()) != 0 {
-				ok := m.list.SelectedItem().(downloadItem).downloadStarted
-				if !ok {
-					item := m.list.SelectedItem().(downloadItem)
-					return m, m.downloadItem(item.id, getDownloadLocation(item.jellyfinItem))
-				} else {
-					item := m.list.SelectedItem().(downloadItem)
-					delete(m.downloading, item.id)
-					item.Cancel()
-					return m.updateItem(item, msg)
-				}
+				return m.toggle(msg)
 			}
-		case "r":
+		case key.Matches(msg, keys.HalfPageUp, keys.HalfPageDown):
+			moveHalfPage(&m.list, key.Matches(msg, keys.HalfPageDown))
+			return m, nil
+		case key.Matches(msg, keys.Pause):
+			if len(m.list.Items()) != 0 {
+				return m.pauseItem()
+			}
+		case key.Matches(msg, keys.PauseAll):
+			return m.pauseQueue()
+		case key.Matches(msg, keys.MoveUp, keys.MoveDown):
+			return m.move(key.Matches(msg, keys.MoveDown))
+		case key.Matches(msg, keys.Pin):
+			return m.togglePin()
+		case key.Matches(msg, keys.PriorityUp):
+			return m.changePriority(1)
+		case key.Matches(msg, keys.PriorityDown):
+			return m.changePriority(-1)
+		case key.Matches(msg, keys.Remove):
 			item := m.list.SelectedItem().(downloadItem)
 			if item.downloadCompleted {
 				path := m.config.Downloaded[item.id]
@@ -173,57 +256,191 @@ func (m downloadModel) Update(msg tea.Msg) (downloadModel, tea.Cmd) {
 				item.Cancel()
 				return m.updateItem(item, msg)
 			}
-		default:
-			var cmd tea.Cmd
-			m.list, cmd = m.list.Update(msg)
-			return m, cmd
 		}
+		var cmd tea.Cmd
+		m.list, cmd = m.list.Update(msg)
+		return m, cmd
+	case mouseMsg:
+		return m.updateMouse(msg)
 	case infoMsg:
 		m.info = msg.info
 	case itemFilteredMsg:
+		if msg.rules.apply(m.config.Selected) {
+			writeConfig(*m.config)
+		}
+		m.items = msg.items
 		m.list = *createList(msg.listItems, true)
+		m.list.KeyMap = keys.listKeyMap(false)
 		m.list.SetShowTitle(false)
-		m.list.SetHeight(m.height - 6)
+		m.list.SetHeight(m.height - 7)
 		m.list.SetWidth(m.width)
 		if len(msg.listItems) > 0 {
-			return m, m.downloadItem(m.getNext())
+			next, location := m.getNext()
+			if next == "" && m.config.WritePlaylists {
+				return m, writePlaylistFiles(m.items, m.config)
+			}
+			if next == "" {
+				return m, nil
+			}
+			return m, tea.Batch(m.downloadItem(next, location), notifyWebhooks(m.queuePayload(webhookQueueStarted), m.config))
 		}
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		m.list.SetHeight(m.height - 6)
+		m.list.SetHeight(m.height - 7)
 		m.list.SetWidth(m.width)
 	case startDownloadingItemMsg: //When the download request is sent
-		return m.updateItem(m.getItem(string(msg)), msg)
+		item := m.getItem(msg.Id)
+		logger.Info("download started", "id", msg.Id, "title", item.jellyfinItem.Name, "dest", msg.dest, "attempt", item.attempts+1)
+		item.maxAttempts = m.config.retryPolicy().MaxAttempts
+		item.stallTimeout = m.config.stallTimeout()
+		m2, cmd := m.updateItem(item, msg)
+		return m2, tea.Batch(cmd, startDownload(msg.Id, msg.dest, m.config))
 
 	case downloadStartedMsg: //When the downloading starts
+		if !m.getItem(msg.Id).downloadStarted {
+			// Paused before the transfer started, its partial file is kept
+			return m, func() tea.Msg {
+				msg.resp.Cancel()
+				return nil
+			}
+		}
 		m.downloading[msg.Id] = msg.resp
-		return m.updateItem(m.getItem(msg.Id), msg)
+		m2, cmd := m.updateItem(m.getItem(msg.Id), msg)
+		return m2, tea.Batch(cmd, waitDownload(msg.Id, msg.resp))
 	case downloadCompletedMsg: //When download is completed
 		delete(m.downloading, msg.Id)
-		m2, cmd := m.updateItem(m.getItem(msg.Id), msg)
+		if item := m.getItem(msg.Id); item.resp != nil {
+			logger.Info("download finished", "id", msg.Id, "file", msg.File, "size", item.resp.BytesComplete(), "duration", item.resp.Duration().Round(time.Millisecond), "speed", ByteCountSI(int64(item.resp.BytesPerSecond()))+"/s")
+		}
+		appendHistory(m.getItem(msg.Id).historyEntry(outcomeCompleted, nil))
+		payload := m.getItem(msg.Id).hookPayload(hookCompleted, msg.File, nil)
+		hookCmd := tea.Batch(runHook(payload, m.config), notifyWebhooks(payload, m.config))
+		item := m.getItem(msg.Id)
+		item.pinned, item.priority, item.rank = false, 0, 0
+		m.config.Queue.forget(msg.Id)
+		m2, cmd := m.updateItem(item, msg)
 		m2.config.Downloaded[msg.Id] = msg.File
 		writeConfig(*m.config)
-		var nextCmd tea.Cmd
-		if len(m2.downloading) == 0 {
-			nextCmd = m.downloadItem(m.getNext())
-		}
-		return m2, tea.Batch(cmd, nextCmd)
+		return m2, tea.Batch(cmd, hookCmd, m2.advance())
 	case downloadFailedMsg: //When download failed
+		if msg.resp != nil && m.downloading[msg.Id] != msg.resp {
+			// A transfer stopped by a pause or a cancel, only the paused
+			// ones keep their partial file
+			logger.Debug("download stopped", "id", msg.Id, "kept", m.kept[msg.resp])
+			if !m.kept[msg.resp] {
+				os.Remove(msg.resp.Filename)
+			}
+			delete(m.kept, msg.resp)
+			return m, nil
+		}
 		delete(m.downloading, msg.Id)
-		return m.updateItem(m.getItem(msg.Id), msg)
+		item := m.getItem(msg.Id)
+		logger.Error("download failed", "id", msg.Id, "error", msg.err, "attempt", item.attempts, "stalled", item.stalled)
+		var retryCmd tea.Cmd
+		policy := m.config.retryPolicy()
+		if msg.resp != nil {
+			// A stalled transfer restarts from its partial file
+			if !m.kept[msg.resp] || item.attempts >= policy.MaxAttempts {
+				os.Remove(msg.resp.Filename)
+			}
+			delete(m.kept, msg.resp)
+		}
+		if item.stalled {
+			item.stalled = false
+			item.lastProgress = time.Time{}
+			msg.Reason = fmt.Sprintf("Stalled: no data received for %s", item.stallTimeout)
+			if item.attempts < policy.MaxAttempts {
+				m2, cmd := m.updateItem(item, msg)
+				return m2, tea.Batch(cmd, m.downloadItem(item.id, getDownloadLocation(item.jellyfinItem)))
+			}
+		} else if item.downloadStarted && item.attempts < policy.MaxAttempts && policy.Retryable(msg.err) {
+			delay := policy.Backoff(item.attempts)
+			item.nextRetry = time.Now().Add(delay)
+			retryCmd = tea.Tick(delay, func(time.Time) tea.Msg {
+				return retryDownloadMsg(msg.Id)
+			})
+		}
+		if item.downloadStarted && retryCmd == nil {
+			appendHistory(item.historyEntry(outcomeFailed, msg.err))
+			payload := item.hookPayload(hookFailed, "", msg.err)
+			hookCmd := tea.Batch(runHook(payload, m.config), notifyWebhooks(payload, m.config))
+			m2, cmd := m.updateItem(item, msg)
+			return m2, tea.Batch(cmd, hookCmd, m2.advance())
+		}
+		m2, cmd := m.updateItem(item, msg)
+		return m2, tea.Batch(cmd, retryCmd)
+	case retryDownloadMsg: //When a failed download should be tried again
+		item := m.getItem(string(msg))
+		if item.downloadStarted || item.downloadCompleted || item.nextRetry.IsZero() {
+			return m, nil
+		}
+		return m, m.downloadItem(item.id, getDownloadLocation(item.jellyfinItem))
+	case hookDoneMsg:
+		if msg.err != nil {
+			logger.Error("hook failed", "event", msg.payload.Event, "id", msg.payload.Id, "error", msg.err, "output", msg.output)
+		} else {
+			logger.Info("hook finished", "event", msg.payload.Event, "id", msg.payload.Id, "output", msg.output)
+		}
+		if msg.payload.Id == "" {
+			m.info = msg.String()
+			return m, nil
+		}
+		for _, v := range m.list.Items() {
+			if item := v.(downloadItem); item.id == msg.payload.Id {
+				item.hook = msg.String()
+				return m.updateItem(item, msg)
+			}
+		}
+		return m, nil
+	case webhookDoneMsg:
+		if msg.err != nil {
+			logger.Error("webhook failed", "host", msg.host, "event", msg.event, "error", msg.err)
+			m.info = errorStyle.Render(fmt.Sprintf("Webhook %s (%s) failed: %s", msg.host, msg.event, msg.err))
+		} else {
+			logger.Info("webhook sent", "host", msg.host, "event", msg.event)
+		}
+		return m, nil
+	case schedulePausedMsg:
+		if !m.paused {
+			logger.Info("outside the download schedule", "until", msg.until)
+		}
+		m.paused = true
+		m.pausedUntil = msg.until
+		return m, nil
 	case tickMsg:
-		cmds = append(cmds, tickCmd())
+		var cmd tea.Cmd
+		m, cmd = m.applySchedule()
+		cmds = append(cmds, tickCmd(), cmd)
 	}
 
 	for i, v := range m.list.Items() {
 		dlItem, cmd := v.(downloadItem).Update(msg)
+		if dlItem.stalled && !v.(downloadItem).stalled {
+			// Cancelled to be restarted, like a pause keeps its partial file
+			m.kept[dlItem.resp] = true
+		}
 		cmdList := m.list.SetItem(i, dlItem)
 		cmds = append(cmds, cmd, cmdList)
 	}
 	return m, tea.Batch(cmds...)
 }
 
+// toggle starts the highlighted download, or cancels it when it is running.
+func (m downloadModel) toggle(msg tea.Msg) (downloadModel, tea.Cmd) {
+	item := m.list.SelectedItem().(downloadItem)
+	if !item.downloadStarted {
+		item.attempts = 0
+		item.nextRetry = time.Time{}
+		m2, cmd := m.updateItem(item, msg)
+		return m2, tea.Batch(cmd, m.downloadItem(item.id, getDownloadLocation(item.jellyfinItem)))
+	}
+	delete(m.downloading, item.id)
+	appendHistory(item.historyEntry(outcomeCancelled, nil))
+	item.Cancel()
+	return m.updateItem(item, msg)
+}
+
 func (m downloadModel) View() string {
 	if m.config.Selected.Size() == 0 {
 		return "No items selected"
@@ -235,20 +452,45 @@ func (m downloadModel) View() string {
 
 	padding := lipgloss.NewStyle().Margin(0, 1)
 
-	return lipgloss.JoinVertical(lipgloss.Left, padding.Render(m.list.View()), m.info)
+	items := make([]jellyfin.Item, 0, len(m.items))
+	for _, v := range m.items {
+		items = append(items, v)
+	}
+	summary := getSelectionSummary(items, m.config).String()
+
+	if m.queuePaused {
+		summary += "  " + progressStyle.Render("⏸ Queue paused, press "+keys.PauseAll.Help().Key+" to resume")
+	} else if m.paused {
+		summary += "  " + progressStyle.Render(pausedView(m.pausedUntil))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, padding.Render(summary), padding.Render(m.list.View()), m.info)
 }
 
 type itemFilteredMsg struct {
 	listItems []list.Item
-	items     map[string]JellyfinItem
+	items     map[string]jellyfin.Item
+	rules     ruleChanges
+}
+
+// filterItems loads the items of the selection, once changed by the rules.
+func (m downloadModel) filterItems() tea.Cmd {
+	rules := copyRules(m.config)
+	selected := NewSet()
+	selected.AddAll(m.config.Selected.Values())
+	return func() tea.Msg {
+		changes := evaluateRules(rules, m.config)
+		changes.apply(selected)
+		return m.loadItems(selected.Values(), changes)
+	}
 }
 
-func (m downloadModel) filterItems() tea.Msg {
-	items := getItems(m.config.Selected.Values(), m.config).Items
-	downloaded := getItems(getMapKeys(m.config.Downloaded), m.config).Items
+func (m downloadModel) loadItems(selected []string, changes ruleChanges) tea.Msg {
+	items := getItems(context.Background(), selected, m.config).Items
+	downloaded := getItems(context.Background(), getMapKeys(m.config.Downloaded), m.config).Items
 	items = append(items, downloaded...)
 
-	msg := itemFilteredMsg{make([]list.Item, 0), make(map[string]JellyfinItem)}
+	msg := itemFilteredMsg{make([]list.Item, 0), make(map[string]jellyfin.Item), changes}
 	for _, v := range items {
 		if !v.IsFolder {
 			_, isDl := m.config.Downloaded[v.Id]
@@ -258,6 +500,7 @@ func (m downloadModel) filterItems() tea.Msg {
 				downloadCompleted: isDl,
 				jellyfinItem:      v,
 			}
+			item = item.queued(m.config.Queue)
 			msg.listItems = append(msg.listItems, item)
 		}
 		msg.items[v.Id] = v
@@ -266,22 +509,21 @@ func (m downloadModel) filterItems() tea.Msg {
 	return msg
 }
 
-func getTitle(item JellyfinItem) string {
+func getTitle(item jellyfin.Item) string {
 	name := strconv.Itoa(item.EpisodeNumber) + ". " + item.Name
-	name = lipgloss.NewStyle().Foreground(lipgloss.Color("190")).Render(name)
-	dot := lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Render(" • ")
+	name = textStyle.Render(name)
+	dot := mutedStyle.Render(" • ")
 	if item.SeriesName != "" {
-		serieStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
-		name = serieStyle.Render(item.SeriesName) + dot +
-			serieStyle.Render(item.SeasonName) + dot +
+		name = highlightStyle.Render(item.SeriesName) + dot +
+			highlightStyle.Render(item.SeasonName) + dot +
 			name
 	} else {
-		name = lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Render("Film") + dot + name
+		name = highlightStyle.Render("Film") + dot + name
 	}
 	return name
 }
 
-func getDownloadLocation(item JellyfinItem) string {
+func getDownloadLocation(item jellyfin.Item) string {
 	if item.SeriesName != "" {
 		return filepath.Join("Series", item.SeriesName, item.SeasonName)
 	} else {
@@ -292,7 +534,10 @@ func getDownloadLocation(item JellyfinItem) string {
 type downloadFailedMsg struct {
 	Id     string
 	Reason string
+	err    error
+	resp   *grab.Response // the failed transfer, nil when it could not start
 }
+type retryDownloadMsg string
 type downloadCompletedMsg struct {
 	Id   string
 	File string
@@ -307,27 +552,119 @@ func (m downloadModel) downloadItem(item, itemDestination string) tea.Cmd {
 	if isDl {
 		return nil
 	}
-	dest := m.config.DownloadLocation
+	if m.queuePaused {
+		return nil
+	}
+	now := time.Now()
+	window, open := m.config.Schedule.active(now)
+	if !open {
+		return sendMessage(schedulePausedMsg{m.config.Schedule.nextOpen(now)})
+	}
+	bandwidth.SetRate(window.rate())
+	dest := path.Join(getDownloadRoot(m.config), itemDestination)
+
+	checkError(os.MkdirAll(dest, os.ModePerm))
+	return sendMessage(startDownloadingItemMsg{item, dest})
+}
+
+func getDownloadRoot(config *Config) string {
+	dest := config.DownloadLocation
 	if dest == "" {
 		p, err := os.UserHomeDir()
 		checkError(err)
 		dest = path.Join(p, "Jellyfin")
 	}
+	return dest
+}
 
-	dest = path.Join(dest, itemDestination)
+type schedulePausedMsg struct {
+	until time.Time
+}
 
-	checkError(os.MkdirAll(dest, os.ModePerm))
-	return func() tea.Msg {
-		file, err := downloadFile(item, dest, m.config)
-		if err != nil {
-			return downloadFailedMsg{item, err.Error()}
+// applySchedule follows the download windows: it caps the bandwidth to the
+// one of the open window, pauses the running downloads when it closes and
+// starts the queue again when the next one opens.
+func (m downloadModel) applySchedule() (downloadModel, tea.Cmd) {
+	now := time.Now()
+	window, open := m.config.Schedule.active(now)
+	bandwidth.SetRate(window.rate())
+	switch {
+	case open && m.paused:
+		logger.Info("download window opened")
+		m.paused = false
+		m.pausedUntil = time.Time{}
+		var cmds []tea.Cmd
+		for _, v := range m.list.Items() {
+			if item := v.(downloadItem); !item.pausedUntil.IsZero() {
+				var cmd tea.Cmd
+				m, cmd = m.updateItem(item, schedulePausedMsg{})
+				cmds = append(cmds, cmd)
+			}
+		}
+		if len(m.downloading) == 0 {
+			cmds = append(cmds, m.downloadItem(m.getNext()))
+		}
+		return m, tea.Batch(cmds...)
+	case !open && len(m.downloading) != 0 && m.config.Schedule.pauseOnClose():
+		return m.pauseAll(m.config.Schedule.nextOpen(now))
+	}
+	return m, nil
+}
+
+// pauseAll stops the running downloads until the next window. Their partial
+// files are kept, so they resume where they stopped.
+func (m downloadModel) pauseAll(until time.Time) (downloadModel, tea.Cmd) {
+	logger.Info("download window closed, pausing", "downloads", len(m.downloading), "until", until)
+	msg := schedulePausedMsg{until}
+	cmds := []tea.Cmd{sendMessage(msg)}
+	for id := range m.downloading {
+		var cmd tea.Cmd
+		m, cmd = m.stop(id, msg)
+		cmds = append(cmds, cmd)
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// advance starts the next download once none is running, or runs the drained
+// hook and webhooks when the queue has nothing left to download. Paused items
+// are still pending, the queue drains once they are done.
+func (m downloadModel) advance() tea.Cmd {
+	if len(m.downloading) != 0 {
+		return nil
+	}
+	next, location := m.getNext()
+	if next != "" {
+		return m.downloadItem(next, location)
+	}
+	if m.queuePayload(hookDrained).Queued != 0 {
+		return nil
+	}
+	cmd := tea.Batch(runHook(m.queuePayload(hookDrained), m.config), notifyWebhooks(m.queuePayload(webhookQueueFinished), m.config))
+	if m.config.WritePlaylists {
+		cmd = tea.Batch(writePlaylistFiles(m.items, m.config), cmd)
+	}
+	return cmd
+}
+
+// queuePayload counts the downloads of the queue for an event.
+func (m downloadModel) queuePayload(event string) hookPayload {
+	p := hookPayload{Event: event}
+	for _, v := range m.list.Items() {
+		switch item := v.(downloadItem); {
+		case item.downloadCompleted:
+			p.Completed++
+		case item.fail != "":
+			p.Failed++
+		default:
+			p.Queued++
 		}
-		return downloadCompletedMsg{item, file}
 	}
+	return p
 }
 
 func (m downloadModel) CancelAll() {
-	for _, r := range m.downloading {
+	for id, r := range m.downloading {
+		appendHistory(m.getItem(id).historyEntry(outcomeCancelled, nil))
 		r.Cancel()
 	}
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	shortHelpSize  = 5
+	helpColumnSize = 6
+)
+
+// contextHelp lists the bindings valid in a context, in the order of
+// keyMap.contexts.
+type contextHelp []key.Binding
+
+func (h contextHelp) ShortHelp() []key.Binding {
+	if len(h) <= shortHelpSize {
+		return h
+	}
+	short := append([]key.Binding{}, h[:shortHelpSize-1]...)
+	return append(short, keys.Help)
+}
+
+func (h contextHelp) FullHelp() [][]key.Binding {
+	var columns [][]key.Binding
+	for i := 0; i < len(h); i += helpColumnSize {
+		columns = append(columns, h[i:min(i+helpColumnSize, len(h))])
+	}
+	return columns
+}
+
+func (k keyMap) contextHelp(context string) contextHelp {
+	bindings := k.bindings()
+	var h contextHelp
+	for _, name := range k.contexts()[context] {
+		if b := bindings[name]; b.Enabled() && len(b.Keys()) != 0 {
+			h = append(h, *b)
+		}
+	}
+	return h
+}
+
+func newHelp(width int) help.Model {
+	h := help.New()
+	h.Width = width
+	h.Styles.ShortKey = highlightStyle
+	h.Styles.FullKey = highlightStyle
+	h.Styles.ShortDesc = mutedStyle
+	h.Styles.FullDesc = mutedStyle
+	return h
+}
+
+// helpContext names the bindings valid in the current screen, focus and mode.
+func (m model) helpContext() string {
+	switch m.currentScreen {
+	case diagnosticsScreen:
+		return "diagnostics"
+	case downloadScreen:
+		if m.downloadModel.list.SettingFilter() {
+			return "filter"
+		}
+		return "downloads"
+	case historyScreen:
+		if m.historyModel.list.SettingFilter() {
+			return "filter"
+		}
+		return "history"
+	}
+	if m.focus == bottombar {
+		if m.bottombarModel.input.isActive {
+			return "input"
+		}
+		return "bottombar"
+	}
+	if m.jellyfinViewModel.isFiltering() {
+		return "filter"
+	}
+	return "browser"
+}
+
+// isTyping tells if the keys are typed in a text input.
+func (m model) isTyping() bool {
+	context := m.helpContext()
+	return context == "input" || context == "filter"
+}
+
+// updateHelp handles the overlay and the footer. It returns false for the
+// messages it does not consume.
+func (m model) updateHelp(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	switch {
+	case m.showHelp:
+		if key.Matches(msg, keys.ForceQuit) {
+			return m, tea.Quit, true
+		}
+		m.showHelp = false
+		return m, nil, true
+	case key.Matches(msg, keys.Help) && (!m.isTyping() || msg.Type != tea.KeyRunes):
+		m.showHelp = true
+		return m, nil, true
+	case key.Matches(msg, keys.Footer) && !m.isTyping():
+		m.config.HideHelpFooter = !m.config.HideHelpFooter
+		writeConfig(*m.config)
+		next, cmd := m.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		return next.(model), cmd, true
+	}
+	return m, nil, false
+}
+
+func (m model) footerHeight() int {
+	if m.config == nil || m.config.HideHelpFooter {
+		return 0
+	}
+	return 1
+}
+
+func (m model) footerView() string {
+	return newHelp(m.width).ShortHelpView(keys.contextHelp(m.helpContext()).ShortHelp())
+}
+
+func (m model) helpView() string {
+	context := m.helpContext()
+	h := newHelp(m.width)
+	box := docStyle.Copy().UnsetWidth().UnsetHeight().UnsetMargins().Padding(1, 2)
+	view := lipgloss.JoinVertical(lipgloss.Left,
+		detailTitleStyle.Render("Keys · "+context),
+		"",
+		h.FullHelpView(keys.contextHelp(context).FullHelp()),
+		"",
+		detailMutedStyle.Render("Press any key to close"),
+	)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box.Render(view))
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"jellyfindl/jellyfin"
+)
+
+const (
+	outcomeCompleted = "completed"
+	outcomeFailed    = "failed"
+	outcomeCancelled = "cancelled"
+)
+
+// historyEntry records how a download ended. Speed is the average in bytes
+// per second.
+type historyEntry struct {
+	Id       string
+	Title    string
+	Path     string
+	Size     int64
+	Start    time.Time
+	End      time.Time
+	Speed    float64
+	Attempts int
+	Outcome  string
+	Error    string `json:",omitempty"`
+}
+
+func getHistoryFilePath() string {
+	return filepath.Join(getStateDir(), "history.jsonl")
+}
+
+// appendHistory adds an entry at the end of the history file, the previous
+// entries are never rewritten.
+func appendHistory(entry historyEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error("history entry", "error", err)
+		return
+	}
+	path := getHistoryFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		logger.Error("history directory", "error", err)
+		return
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logger.Error("history file", "error", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		logger.Error("history file", "error", err)
+	}
+}
+
+// loadHistory reads the history, newest first. Lines which cannot be parsed,
+// like one cut by a crash, are skipped.
+func loadHistory() ([]historyEntry, error) {
+	file, err := os.Open(getHistoryFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logger.Warn("skipping history line", "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, scanner.Err()
+}
+
+// historyEntry describes the download of the item ending with outcome.
+func (i downloadItem) historyEntry(outcome string, err error) historyEntry {
+	entry := historyEntry{
+		Id:       i.id,
+		Title:    plainTitle(i.jellyfinItem),
+		Attempts: i.attempts,
+		Outcome:  outcome,
+		End:      time.Now(),
+	}
+	if i.resp != nil {
+		entry.Path = i.resp.Filename
+		entry.Size = i.resp.BytesComplete()
+		entry.Start = i.resp.Start
+		if !i.resp.End.IsZero() {
+			entry.End = i.resp.End
+		}
+		if duration := entry.End.Sub(entry.Start).Seconds(); duration > 0 {
+			entry.Speed = float64(entry.Size) / duration
+		}
+	} else {
+		entry.Start = entry.End
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+func plainTitle(item jellyfin.Item) string {
+	if item.SeriesName != "" {
+		return fmt.Sprintf("%s • %s • %d. %s", item.SeriesName, item.SeasonName, item.EpisodeNumber, item.Name)
+	}
+	return item.Name
+}
+
+var historyHeader = []string{"id", "title", "path", "size", "start", "end", "duration_seconds", "speed_bytes_per_second", "attempts", "outcome", "error"}
+
+func writeHistoryCSV(w io.Writer, entries []historyEntry) error {
+	writer := csv.NewWriter(w)
+	writer.Write(historyHeader)
+	for _, e := range entries {
+		writer.Write([]string{
+			e.Id,
+			e.Title,
+			e.Path,
+			strconv.FormatInt(e.Size, 10),
+			e.Start.Format(time.RFC3339),
+			e.End.Format(time.RFC3339),
+			strconv.FormatFloat(e.End.Sub(e.Start).Seconds(), 'f', 0, 64),
+			strconv.FormatFloat(e.Speed, 'f', 0, 64),
+			strconv.Itoa(e.Attempts),
+			e.Outcome,
+			e.Error,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeHistoryJSON(w io.Writer, entries []historyEntry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if entries == nil {
+		entries = []historyEntry{}
+	}
+	return encoder.Encode(entries)
+}
+
+func writeHistory(w io.Writer, format string, entries []historyEntry) error {
+	switch format {
+	case "csv":
+		return writeHistoryCSV(w, entries)
+	case "json":
+		return writeHistoryJSON(w, entries)
+	}
+	return fmt.Errorf("unknown history format %q", format)
+}
+
+// exportHistory writes the entries in the download location.
+func exportHistory(format string, entries []historyEntry, config *Config) (string, error) {
+	path := filepath.Join(getDownloadRoot(config), "jellyfindl-history-"+time.Now().Format("2006-01-02-150405")+"."+format)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return path, writeHistory(file, format, entries)
+}
+
+/* History screen */
+type historyItem struct {
+	entry historyEntry
+}
+
+func (i historyItem) Title() string {
+	return itemMarker(false, i.entry.Outcome == outcomeCompleted) + i.entry.Title
+}
+
+func (i historyItem) Description() string {
+	e := i.entry
+	var outcome string
+	switch e.Outcome {
+	case outcomeCompleted:
+		outcome = successStyle.Render("✔ " + e.Outcome)
+	case outcomeFailed:
+		outcome = errorStyle.Render("❌ " + e.Outcome + ": " + e.Error)
+	default:
+		outcome = mutedStyle.Render(e.Outcome)
+	}
+	facts := []string{
+		e.End.Local().Format("2006-01-02 15:04"),
+		ByteCountSI(e.Size),
+		ByteCountSI(int64(e.Speed)) + "/s",
+		e.End.Sub(e.Start).Round(time.Second).String(),
+		fmt.Sprintf("%d attempts", e.Attempts),
+	}
+	return outcome + mutedStyle.Render(" · "+strings.Join(facts, " · "))
+}
+
+func (i historyItem) FilterValue() string {
+	return i.entry.Title + " " + i.entry.Outcome + " " + i.entry.End.Local().Format("2006-01-02")
+}
+
+type historyModel struct {
+	config        *Config
+	list          list.Model
+	info          string
+	width, height int
+}
+
+type historyLoadedMsg struct {
+	entries []historyEntry
+	err     error
+}
+
+func (m *historyModel) InitModel() {
+	m.list = *createList(make([]list.Item, 0), true)
+	m.list.KeyMap = keys.listKeyMap(false)
+	m.list.Title = "History"
+	m.list.Styles.Title = focusedTitleStyle
+	m.list.SetSize(m.width, m.height-1)
+}
+
+func (m historyModel) Init() tea.Cmd {
+	return func() tea.Msg {
+		entries, err := loadHistory()
+		return historyLoadedMsg{entries, err}
+	}
+}
+
+func (m historyModel) Update(msg tea.Msg) (historyModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case historyLoadedMsg:
+		if msg.err != nil {
+			m.info = errorStyle.Render("Cannot read the history: " + msg.err.Error())
+		}
+		items := make([]list.Item, len(msg.entries))
+		for i, e := range msg.entries {
+			items[i] = historyItem{e}
+		}
+		return m, m.list.SetItems(items)
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.list.SetSize(m.width, m.height-1)
+		return m, nil
+	case tea.KeyMsg:
+		if m.list.SettingFilter() {
+			break
+		}
+		switch {
+		case key.Matches(msg, keys.HalfPageUp, keys.HalfPageDown):
+			moveHalfPage(&m.list, key.Matches(msg, keys.HalfPageDown))
+			return m, nil
+		case key.Matches(msg, keys.ExportCSV, keys.ExportJSON):
+			format := "csv"
+			if key.Matches(msg, keys.ExportJSON) {
+				format = "json"
+			}
+			var entries []historyEntry
+			for _, it := range m.list.VisibleItems() {
+				entries = append(entries, it.(historyItem).entry)
+			}
+			path, err := exportHistory(format, entries, m.config)
+			if err != nil {
+				m.info = errorStyle.Render("Export failed: " + err.Error())
+			} else {
+				m.info = fmt.Sprintf("Exported %d entries to %s", len(entries), path)
+			}
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m historyModel) View() string {
+	padding := lipgloss.NewStyle().Margin(0, 1)
+	return lipgloss.JoinVertical(lipgloss.Left, padding.Render(m.list.View()), m.info)
+}
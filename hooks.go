@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	hookCompleted = "completed"
+	hookFailed    = "failed"
+	hookDrained   = "drained"
+
+	defaultHookTimeout = 5 * time.Minute
+	maxHookOutput      = 200
+)
+
+// hookPayload is given to the hook commands, as JSON on their standard input
+// and as JELLYFINDL_* environment variables, and posted to the webhooks. The
+// item fields are empty for the queue events, which only count the downloads.
+type hookPayload struct {
+	Event         string
+	Id            string `json:",omitempty"`
+	Name          string `json:",omitempty"`
+	Type          string `json:",omitempty"`
+	SeriesName    string `json:",omitempty"`
+	SeasonName    string `json:",omitempty"`
+	EpisodeNumber int    `json:",omitempty"`
+	Year          int    `json:",omitempty"`
+	File          string `json:",omitempty"`
+	Size          int64  `json:",omitempty"`
+	Attempts      int    `json:",omitempty"`
+	Error         string `json:",omitempty"`
+	Queued        int    `json:",omitempty"`
+	Completed     int
+	Failed        int
+}
+
+func (p hookPayload) env() []string {
+	vars := map[string]string{
+		"EVENT":          p.Event,
+		"ID":             p.Id,
+		"NAME":           p.Name,
+		"TYPE":           p.Type,
+		"SERIES_NAME":    p.SeriesName,
+		"SEASON_NAME":    p.SeasonName,
+		"EPISODE_NUMBER": strconv.Itoa(p.EpisodeNumber),
+		"YEAR":           strconv.Itoa(p.Year),
+		"FILE":           p.File,
+		"SIZE":           strconv.FormatInt(p.Size, 10),
+		"ATTEMPTS":       strconv.Itoa(p.Attempts),
+		"ERROR":          p.Error,
+		"COMPLETED":      strconv.Itoa(p.Completed),
+		"FAILED":         strconv.Itoa(p.Failed),
+	}
+	env := os.Environ()
+	for name, value := range vars {
+		env = append(env, "JELLYFINDL_"+name+"="+value)
+	}
+	return env
+}
+
+// hookPayload describes the item for the event.
+func (i downloadItem) hookPayload(event, file string, err error) hookPayload {
+	p := hookPayload{
+		Event:         event,
+		Id:            i.id,
+		Name:          i.jellyfinItem.Name,
+		Type:          i.jellyfinItem.Type,
+		SeriesName:    i.jellyfinItem.SeriesName,
+		SeasonName:    i.jellyfinItem.SeasonName,
+		EpisodeNumber: i.jellyfinItem.EpisodeNumber,
+		Year:          i.jellyfinItem.ProductionYear,
+		File:          file,
+		Attempts:      i.attempts,
+	}
+	if i.resp != nil {
+		p.Size = i.resp.BytesComplete()
+		if p.File == "" {
+			p.File = i.resp.Filename
+		}
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	return p
+}
+
+type hookDoneMsg struct {
+	payload hookPayload
+	output  string
+	err     error
+}
+
+func (c *Config) hookCommand(event string) string {
+	switch event {
+	case hookCompleted:
+		return c.Hooks.Completed
+	case hookFailed:
+		return c.Hooks.Failed
+	case hookDrained:
+		return c.Hooks.Drained
+	}
+	return ""
+}
+
+func (c *Config) hookTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(c.Hooks.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultHookTimeout
+}
+
+// runHook runs the command configured for the event of the payload through
+// the shell. It returns nil when there is none.
+func runHook(payload hookPayload, config *Config) tea.Cmd {
+	command := config.hookCommand(payload.Event)
+	if command == "" {
+		return nil
+	}
+	timeout := config.hookTimeout()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		stdin, err := json.Marshal(payload)
+		if err != nil {
+			return hookDoneMsg{payload, "", err}
+		}
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		}
+		cmd.Env = payload.env()
+		cmd.Stdin = bytes.NewReader(stdin)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err = cmd.Run()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		return hookDoneMsg{payload, strings.TrimSpace(output.String()), err}
+	}
+}
+
+// String sums up the result of a hook in one line.
+func (msg hookDoneMsg) String() string {
+	status := "exit 0"
+	if msg.err != nil {
+		status = msg.err.Error()
+	}
+	s := fmt.Sprintf("Hook %s: %s", msg.payload.Event, status)
+	if lines := strings.Split(msg.output, "\n"); msg.output != "" {
+		last := lines[len(lines)-1]
+		if len(last) > maxHookOutput {
+			last = last[:maxHookOutput] + "…"
+		}
+		s += " · " + last
+	}
+	return s
+}
@@ -1,9 +1,9 @@
 package main
 
 import (
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 type Input int
@@ -27,13 +27,13 @@ func (m inputModel) Update(msg tea.Msg) (inputModel, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
+		switch {
+		case key.Matches(msg, keys.ForceQuit):
 			return m, tea.Quit
-		case "enter":
+		case key.Matches(msg, keys.Confirm):
 			m.isActive = false
 			return m, m.doneInput
-		case "esc":
+		case key.Matches(msg, keys.Cancel):
 			m.isActive = false
 			return m, m.cancelInput
 		}
@@ -48,7 +48,7 @@ func (m inputModel) View() string {
 
 func InitInput(id Input, input, de, placeholder string) inputModel {
 	ti := textinput.New()
-	ti.Prompt = input + lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render(" ? ")
+	ti.Prompt = input + promptStyle.Render(" ? ")
 	ti.Placeholder = placeholder
 	ti.SetValue(de)
 	ti.Focus()
@@ -0,0 +1,201 @@
+// Package jellyfin is a small client for the parts of the Jellyfin API used
+// by jellyfindl.
+package jellyfin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cavaliergopher/grab/v3"
+)
+
+type Client struct {
+	Endpoint string
+	APIKey   string
+	UserId   string
+
+	ClientName string
+	Device     string
+	DeviceId   string
+	Version    string
+
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+
+	// Headers are added to every request, for example the service token of
+	// an authenticating reverse proxy.
+	Headers http.Header
+	// BasicAuth, when set, is sent with every request.
+	BasicAuth *BasicAuth
+
+	// OnRequest is called before every request is sent.
+	OnRequest func(req *http.Request)
+	// OnDone is called once a request is finished with how long it took, err
+	// is set when it failed.
+	OnDone func(req *http.Request, resp *http.Response, latency time.Duration, err error)
+
+	// RateLimiter, when set, limits the speed of the downloads.
+	RateLimiter grab.RateLimiter
+}
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+var defaultHTTPClient, _ = NewHTTPClient(DefaultTransportOptions())
+
+// NewClient returns a client identified as a new device. Set DeviceId to a
+// persisted value so the server sees the same device across runs.
+func NewClient(endpoint, apiKey, userId string) *Client {
+	return &Client{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		APIKey:     apiKey,
+		UserId:     userId,
+		ClientName: "Download Client",
+		Device:     Hostname(),
+		DeviceId:   NewDeviceId(),
+		Version:    "1.0",
+		HTTPClient: defaultHTTPClient,
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
+// NewDeviceId returns a random device ID.
+func NewDeviceId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Hostname returns the name of the machine, used as the default device name.
+func Hostname() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "Unknown"
+	}
+	return hostname
+}
+
+// Authorization returns the value of the X-Emby-Authorization header.
+func (c *Client) Authorization() string {
+	return fmt.Sprintf("MediaBrowser Client=%q, Device=%q, DeviceId=%q, Version=%q, Token=%q",
+		c.ClientName, c.Device, c.DeviceId, c.Version, c.APIKey)
+}
+
+func (c *Client) userId() string {
+	if c.UserId == "" {
+		return "0"
+	}
+	return c.UserId
+}
+
+func (c *Client) url(path string, values url.Values) string {
+	u := c.Endpoint + path
+	if len(values) != 0 {
+		u += "?" + values.Encode()
+	}
+	return u
+}
+
+// NewRequest creates a request to the API with the authorization header set.
+func (c *Client) NewRequest(ctx context.Context, method, path string, values url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path, values), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(req)
+	return req, nil
+}
+
+func (c *Client) authorize(req *http.Request) {
+	for key, values := range c.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if c.BasicAuth != nil {
+		req.SetBasicAuth(c.BasicAuth.Username, c.BasicAuth.Password)
+	}
+	req.Header.Set("X-Emby-Authorization", c.Authorization())
+}
+
+// Do sends a request, retrying it according to the retry policy, and maps
+// failures to the errors of this package.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(req)
+		if err == nil || attempt >= c.Retry.MaxAttempts || !c.Retry.Retryable(err) {
+			return resp, err
+		}
+
+		select {
+		case <-time.After(c.Retry.Backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.OnRequest != nil {
+		c.OnRequest(req)
+	}
+
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err == nil {
+		err = checkResponse(resp)
+	} else if req.Context().Err() == nil {
+		err = classifyError(err)
+	}
+
+	if c.OnDone != nil {
+		c.OnDone(req, resp, time.Since(start), err)
+	}
+	return resp, err
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusBadRequest:
+		return ErrBadUserId
+	case http.StatusNotFound:
+		return ErrNotFound
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	return &StatusError{resp.StatusCode, resp.Request.URL.String(), string(body)}
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, values url.Values, v interface{}) error {
+	req, err := c.NewRequest(ctx, http.MethodGet, path, values)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
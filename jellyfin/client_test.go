@@ -0,0 +1,214 @@
+package jellyfin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestItems(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Users/user/Items" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("parentId"); got != "parent" {
+			t.Errorf("parentId = %q", got)
+		}
+		if got := r.URL.Query().Get("fields"); got != "Overview,MediaSources" {
+			t.Errorf("fields = %q", got)
+		}
+		for _, name := range []string{"enableImages", "enableUserData"} {
+			if got, ok := r.URL.Query()[name]; ok {
+				t.Errorf("%s = %q, want the server default", name, got)
+			}
+		}
+		if got := r.Header.Get("X-Emby-Authorization"); !strings.Contains(got, `Token="key"`) || !strings.Contains(got, `DeviceId="device"`) {
+			t.Errorf("authorization = %q", got)
+		}
+		w.Write([]byte(`{"Items":[{"Name":"Movie","Id":"1","MediaSources":[{"Size":42}]}],"TotalRecordCount":1}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "key", "user")
+	client.DeviceId = "device"
+	res, err := client.Items(context.Background(), Query{ParentId: "parent", Fields: []string{"Overview", "MediaSources"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 1 || res.Items[0].Name != "Movie" || res.TotalRecordCount != 1 {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	if size := res.Items[0].Size(); size != 42 {
+		t.Errorf("Size() = %d", size)
+	}
+}
+
+func TestNewDeviceId(t *testing.T) {
+	if NewDeviceId() == NewDeviceId() {
+		t.Error("device IDs should be random")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("CF-Access-Client-Id"); got != "service" {
+			t.Errorf("%s: CF-Access-Client-Id = %q", r.URL.Path, got)
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			t.Errorf("%s: basic auth = %q, %q, %v", r.URL.Path, user, password, ok)
+		}
+		if r.URL.Path == "/Items/1/Download" {
+			w.Header().Set("Content-Disposition", `attachment; filename="movie.mkv"`)
+			w.Write([]byte("content"))
+			return
+		}
+		w.Write([]byte(`{"Items":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "key", "user")
+	client.Headers = http.Header{"CF-Access-Client-Id": {"service"}}
+	client.BasicAuth = &BasicAuth{"user", "secret"}
+	if _, err := client.Items(context.Background(), Query{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Image(context.Background(), "1", "Primary"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Download(context.Background(), "1", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusBadRequest, ErrBadUserId},
+		{http.StatusNotFound, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}))
+
+		_, err := NewClient(server.URL, "key", "user").Items(context.Background(), Query{})
+		if !errors.Is(err, test.err) {
+			t.Errorf("status %d: got %v, want %v", test.status, err, test.err)
+		}
+		server.Close()
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "key", "user")
+	client.Retry = RetryPolicy{}
+	_, err := client.User(context.Background())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("got %v, want a StatusError", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"Items":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "key", "user")
+	client.Retry.BaseDelay = time.Millisecond
+	if _, err := client.Items(context.Background(), Query{}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+
+	calls = 0
+	client.Retry.MaxAttempts = 2
+	if _, err := client.Items(context.Background(), Query{}); err == nil {
+		t.Error("expected an error once the attempts are exhausted")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	capped := RetryPolicy{BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+	uncapped := RetryPolicy{BaseDelay: time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 6: 32 * time.Second} {
+		// The jitter keeps the delay between half of it and all of it
+		if got := uncapped.Backoff(attempt); got < want/2 || got > want {
+			t.Errorf("uncapped attempt %d: got %s, want about %s", attempt, got, want)
+		}
+		if want > capped.MaxDelay {
+			want = capped.MaxDelay
+		}
+		if got := capped.Backoff(attempt); got < want/2 || got > want {
+			t.Errorf("capped attempt %d: got %s, want about %s", attempt, got, want)
+		}
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := server.URL
+	server.Close()
+
+	client := NewClient(endpoint, "key", "user")
+	client.Retry = RetryPolicy{}
+	_, err := client.Items(context.Background(), Query{})
+	var unreachable *UnreachableError
+	if !errors.As(err, &unreachable) {
+		t.Fatalf("got %v, want an UnreachableError", err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Items/1/Download" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="movie.mkv"`)
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	resp, err := NewClient(server.URL, "key", "user").Download(context.Background(), "1", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "movie.mkv"))
+	if err != nil || string(b) != "content" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,43 @@
+package jellyfin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/cavaliergopher/grab/v3"
+)
+
+// DownloadURL returns the URL serving the original file of an item.
+func (c *Client) DownloadURL(id string) string {
+	return c.url("/Items/"+url.PathEscape(id)+"/Download", nil)
+}
+
+// Download starts downloading the original file of an item into dest, which
+// can be a directory. The transfer runs in the background, the returned
+// response reports its progress and its error, one of the errors of this
+// package when the server could not serve the file.
+func (c *Client) Download(ctx context.Context, id, dest string) (*grab.Response, error) {
+	req, err := grab.NewRequest(dest, c.DownloadURL(id))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.RateLimiter = c.RateLimiter
+	c.authorize(req.HTTPRequest)
+
+	grabClient := grab.NewClient()
+	grabClient.HTTPClient = downloadSender{c}
+	return grabClient.Do(req), nil
+}
+
+// downloadSender sends the requests of a download like the other requests of
+// the client, with its hooks and errors, but only once: the caller retries
+// the download so it can resume it.
+type downloadSender struct {
+	c *Client
+}
+
+func (s downloadSender) Do(req *http.Request) (*http.Response, error) {
+	return s.c.do(req)
+}
@@ -0,0 +1,59 @@
+package jellyfin_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jellyfindl/jellyfin"
+	"jellyfindl/jellyfin/jellyfintest"
+)
+
+func TestDownloadResume(t *testing.T) {
+	server := jellyfintest.NewServer(jellyfintest.Library())
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bunny.mkv"), []byte("big buck"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := jellyfin.NewClient(server.URL, jellyfintest.APIKey, jellyfintest.UserId)
+	resp, err := client.Download(context.Background(), "movie1", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.DidResume {
+		t.Error("the download should resume from the partial file")
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "bunny.mkv"))
+	if err != nil || string(b) != "big buck bunny" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+}
+
+func TestDownloadNotFound(t *testing.T) {
+	server := jellyfintest.NewServer(jellyfintest.Library())
+	defer server.Close()
+
+	client := jellyfin.NewClient(server.URL, jellyfintest.APIKey, jellyfintest.UserId)
+	var requests int
+	client.OnRequest = func(req *http.Request) { requests++ }
+	resp, err := client.Download(context.Background(), "missing", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Err(); !errors.Is(err, jellyfin.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if requests == 0 {
+		t.Error("the download requests should go through OnRequest")
+	}
+}
@@ -0,0 +1,70 @@
+package jellyfin
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+var (
+	// ErrUnauthorized is returned when the server rejects the API key.
+	ErrUnauthorized = errors.New("jellyfin: unauthorized")
+	// ErrBadUserId is returned when the server does not accept the user ID.
+	ErrBadUserId = errors.New("jellyfin: bad user ID")
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = errors.New("jellyfin: not found")
+)
+
+// UnreachableError is returned when the server could not be contacted.
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return "jellyfin: server unreachable: " + e.Err.Error()
+}
+
+func (e *UnreachableError) Unwrap() error { return e.Err }
+
+// CertificateError is returned when the certificate of the server could not
+// be verified.
+type CertificateError struct {
+	Err error
+}
+
+func (e *CertificateError) Error() string {
+	return "jellyfin: certificate error: " + e.Err.Error()
+}
+
+func (e *CertificateError) Unwrap() error { return e.Err }
+
+// classifyError wraps a transport error into a CertificateError or an
+// UnreachableError.
+func classifyError(err error) error {
+	if isCertificateError(err) {
+		return &CertificateError{err}
+	}
+	if _, ok := err.(*url.Error); ok {
+		return &UnreachableError{err}
+	}
+	return err
+}
+
+func isCertificateError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	return errors.As(err, &unknownAuthority) || errors.As(err, &invalid) || errors.As(err, &hostname)
+}
+
+// StatusError is returned when the server responds with an unexpected status.
+type StatusError struct {
+	StatusCode int
+	URL        string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("jellyfin: server responded with error code %d when calling %s: %s", e.StatusCode, e.URL, e.Body)
+}
@@ -0,0 +1,28 @@
+package jellyfin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ImageURL returns the URL of an image of an item, such as "Primary".
+func (c *Client) ImageURL(id, imageType string) string {
+	return c.url("/Items/"+url.PathEscape(id)+"/Images/"+url.PathEscape(imageType), nil)
+}
+
+// Image downloads an image of an item, such as "Primary".
+func (c *Client) Image(ctx context.Context, id, imageType string) ([]byte, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, "/Items/"+url.PathEscape(id)+"/Images/"+url.PathEscape(imageType), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,103 @@
+package jellyfin
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/google/go-querystring/query"
+)
+
+type Item struct {
+	Name,
+	Id,
+	SeriesName,
+	SeasonName,
+	Type string
+	SeasonNumber    int `json:"ParentIndexNumber"`
+	EpisodeNumber   int `json:"IndexNumber"`
+	IsFolder        bool
+	Overview        string
+	RunTimeTicks    int64
+	ProductionYear  int
+	CommunityRating float64
+	Container       string
+	MediaSources    []MediaSource
+	UserData        UserData
+}
+
+type MediaSource struct {
+	Container    string
+	Size         int64
+	MediaStreams []MediaStream
+}
+
+type MediaStream struct {
+	Type,
+	Codec,
+	Language,
+	DisplayTitle string
+	Width,
+	Height int
+}
+
+type UserData struct {
+	Played                bool
+	PlaybackPositionTicks int64
+}
+
+// Size returns the size of the file served by the download endpoint.
+func (i Item) Size() int64 {
+	if len(i.MediaSources) == 0 {
+		return 0
+	}
+	return i.MediaSources[0].Size
+}
+
+type Response struct {
+	Items            []Item
+	TotalRecordCount int
+}
+
+type Query struct {
+	ParentId         string   `url:"parentId,omitempty"`
+	Ids              []string `url:"ids,omitempty"`
+	Fields           []string `url:"fields,omitempty" del:","`
+	IncludeItemTypes []string `url:"includeItemTypes,omitempty" del:","`
+	Recursive        bool     `url:"recursive,omitempty"`
+	SortBy           []string `url:"sortBy,omitempty" del:","`
+	StartIndex       int      `url:"startIndex,omitempty"`
+	Limit            int      `url:"limit,omitempty"`
+	// EnableImages and EnableUserData are left to the server default when
+	// nil.
+	EnableImages   *bool `url:"enableImages,omitempty"`
+	EnableUserData *bool `url:"enableUserData,omitempty"`
+}
+
+// Bool returns a pointer to v, for the optional fields of a Query.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Items queries the items of the user.
+func (c *Client) Items(ctx context.Context, q Query) (Response, error) {
+	values, err := query.Values(q)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var res Response
+	err = c.getJSON(ctx, "/Users/"+url.PathEscape(c.userId())+"/Items", values, &res)
+	return res, err
+}
+
+// Item fetches a single item of the user.
+func (c *Client) Item(ctx context.Context, id string, fields ...string) (Item, error) {
+	res, err := c.Items(ctx, Query{Ids: []string{id}, Fields: fields})
+	if err != nil {
+		return Item{}, err
+	}
+	if len(res.Items) == 0 {
+		return Item{}, ErrNotFound
+	}
+	return res.Items[0], nil
+}
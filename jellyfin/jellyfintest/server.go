@@ -0,0 +1,276 @@
+// Package jellyfintest provides an in-process Jellyfin server serving a
+// fixture library, for tests.
+package jellyfintest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jellyfindl/jellyfin"
+)
+
+const (
+	APIKey = "test-key"
+	UserId = "test-user"
+)
+
+// Entry is an item of the fixture library. Content is served by the download
+// endpoint, under FileName.
+type Entry struct {
+	jellyfin.Item
+	ParentId string
+	FileName string
+	Content  []byte
+}
+
+// Server is a fake Jellyfin server. Its library can be modified and failures
+// injected while it runs.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	entries  []Entry
+	failures []failure
+	requests []string
+}
+
+type failure struct {
+	path   string
+	status int
+	times  int
+}
+
+// NewServer starts a server serving the given library.
+func NewServer(entries []Entry) *Server {
+	s := &Server{entries: entries}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Library returns a small fixture library: two movies and a series with two
+// episodes nested in a season.
+func Library() []Entry {
+	return []Entry{
+		folder("movies", "", "Movies", "CollectionFolder"),
+		folder("shows", "", "Shows", "CollectionFolder"),
+		movie("movie1", "movies", "Big Buck Bunny", "bunny.mkv", "big buck bunny"),
+		movie("movie2", "movies", "Sintel", "sintel.mkv", "sintel"),
+		folder("series1", "shows", "Elephants Dream", "Series"),
+		folder("season1", "series1", "Season 1", "Season"),
+		episode("episode1", "season1", 1, "Proog", "proog.mkv", "first episode"),
+		episode("episode2", "season1", 2, "Emo", "emo.mkv", "second episode"),
+	}
+}
+
+func folder(id, parentId, name, itemType string) Entry {
+	return Entry{Item: jellyfin.Item{Id: id, Name: name, Type: itemType, IsFolder: true}, ParentId: parentId}
+}
+
+func movie(id, parentId, name, fileName, content string) Entry {
+	return Entry{
+		Item: jellyfin.Item{
+			Id:           id,
+			Name:         name,
+			Type:         "Movie",
+			MediaSources: []jellyfin.MediaSource{{Container: "mkv", Size: int64(len(content))}},
+		},
+		ParentId: parentId,
+		FileName: fileName,
+		Content:  []byte(content),
+	}
+}
+
+func episode(id, parentId string, number int, name, fileName, content string) Entry {
+	e := movie(id, parentId, name, fileName, content)
+	e.Type = "Episode"
+	e.SeriesName = "Elephants Dream"
+	e.SeasonName = "Season 1"
+	e.SeasonNumber = 1
+	e.EpisodeNumber = number
+	return e
+}
+
+// Fail makes the next times requests whose path starts with path answer
+// status. HEAD requests are left alone so that the probe sent before a
+// download does not consume the failure.
+func (s *Server) Fail(path string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{path, status, times})
+}
+
+// Requests returns the paths of the requests received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Entry returns the entry with the given id.
+func (s *Server) Entry(id string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.Id == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// injectedFailure records r and consumes the failure registered for its path,
+// if any.
+func (s *Server) injectedFailure(r *http.Request) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.URL.Path)
+	if r.Method == http.MethodHead {
+		return 0
+	}
+	path := r.URL.Path
+	for i, f := range s.failures {
+		if strings.HasPrefix(path, f.path) && f.times > 0 {
+			s.failures[i].times--
+			return f.status
+		}
+	}
+	return 0
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := s.injectedFailure(r); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if !strings.Contains(r.Header.Get("X-Emby-Authorization"), `Token="`+APIKey+`"`) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "Users":
+		if !s.checkUser(w, parts[1]) {
+			return
+		}
+		writeJSON(w, jellyfin.User{Name: "test", Id: UserId})
+	case len(parts) == 3 && parts[0] == "Users" && parts[2] == "Items":
+		if !s.checkUser(w, parts[1]) {
+			return
+		}
+		writeJSON(w, s.items(r))
+	case len(parts) == 3 && parts[0] == "Items" && parts[2] == "Download":
+		s.download(w, r, parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) checkUser(w http.ResponseWriter, userId string) bool {
+	if userId != UserId {
+		http.Error(w, "Error processing request.", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// items answers an items query, supporting the parameters the client sends.
+func (s *Server) items(r *http.Request) jellyfin.Response {
+	values := r.URL.Query()
+	var ids []string
+	for _, v := range values["ids"] {
+		ids = append(ids, strings.Split(v, ",")...)
+	}
+	types := strings.Split(values.Get("includeItemTypes"), ",")
+	recursive := values.Get("recursive") == "true"
+	parentId := values.Get("parentId")
+
+	s.mu.Lock()
+	var matching []jellyfin.Item
+	for _, e := range s.entries {
+		switch {
+		case len(ids) != 0:
+			if !contains(ids, e.Id) {
+				continue
+			}
+		case recursive:
+			if parentId != "" && !s.isDescendant(e, parentId) {
+				continue
+			}
+		case e.ParentId != parentId:
+			continue
+		}
+		if values.Get("includeItemTypes") != "" && !contains(types, e.Type) {
+			continue
+		}
+		item := e.Item
+		if values.Get("enableUserData") == "false" {
+			item.UserData = jellyfin.UserData{}
+		}
+		matching = append(matching, item)
+	}
+	s.mu.Unlock()
+
+	res := jellyfin.Response{TotalRecordCount: len(matching)}
+	start, _ := strconv.Atoi(values.Get("startIndex"))
+	if start > len(matching) {
+		start = len(matching)
+	}
+	end := len(matching)
+	if limit, err := strconv.Atoi(values.Get("limit")); err == nil && start+limit < end {
+		end = start + limit
+	}
+	res.Items = matching[start:end]
+	return res
+}
+
+func (s *Server) isDescendant(e Entry, ancestor string) bool {
+	for e.ParentId != "" {
+		if e.ParentId == ancestor {
+			return true
+		}
+		var found bool
+		for _, parent := range s.entries {
+			if parent.Id == e.ParentId {
+				e, found = parent, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return false
+}
+
+// download serves the content of an entry, honouring Range requests so
+// interrupted downloads can be resumed.
+func (s *Server) download(w http.ResponseWriter, r *http.Request, id string) {
+	e, ok := s.Entry(id)
+	if !ok || e.Content == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+e.FileName+`"`)
+	http.ServeContent(w, r, e.FileName, time.Time{}, bytes.NewReader(e.Content))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package jellyfin
+
+import (
+	"context"
+	"net/url"
+)
+
+type PlaybackInfo struct {
+	MediaSources  []MediaSource
+	PlaySessionId string
+}
+
+// PlaybackInfo fetches the media sources the server offers for an item.
+func (c *Client) PlaybackInfo(ctx context.Context, id string) (PlaybackInfo, error) {
+	var info PlaybackInfo
+	err := c.getJSON(ctx, "/Items/"+url.PathEscape(id)+"/PlaybackInfo", url.Values{"userId": {c.userId()}}, &info)
+	return info, err
+}
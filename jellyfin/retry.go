@@ -0,0 +1,87 @@
+package jellyfin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/cavaliergopher/grab/v3"
+)
+
+// RetryPolicy describes which failed requests are retried and how long to
+// wait between attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 0 or 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the second attempt, it doubles on every
+	// attempt up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryableStatus lists the HTTP status codes worth retrying.
+	RetryableStatus []int
+	// RetryNetworkErrors retries when the server could not be reached.
+	RetryNetworkErrors bool
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:        3,
+		BaseDelay:          time.Second,
+		MaxDelay:           30 * time.Second,
+		RetryableStatus:    []int{408, 429, 500, 502, 503, 504},
+		RetryNetworkErrors: true,
+	}
+}
+
+// Retryable reports whether a request that failed with err should be tried
+// again.
+func (p RetryPolicy) Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return p.retryableStatus(statusErr.StatusCode)
+	}
+	var grabStatus grab.StatusCodeError
+	if errors.As(err, &grabStatus) {
+		return p.retryableStatus(int(grabStatus))
+	}
+
+	var unreachable *UnreachableError
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &unreachable) || errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return p.RetryNetworkErrors
+	}
+	return false
+}
+
+func (p RetryPolicy) retryableStatus(code int) bool {
+	for _, v := range p.RetryableStatus {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay to wait after the given failed attempt, an
+// exponential backoff with jitter.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay == 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay != 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
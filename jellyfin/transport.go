@@ -0,0 +1,161 @@
+package jellyfin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TransportOptions configures the HTTP client used for the API and the
+// downloads. Zero values disable the matching timeout.
+type TransportOptions struct {
+	// ConnectTimeout limits how long establishing a connection may take.
+	ConnectTimeout time.Duration
+	// ResponseHeaderTimeout limits how long to wait for the response headers
+	// once the request is sent.
+	ResponseHeaderTimeout time.Duration
+	// IdleReadTimeout fails a response when no data is read for this long.
+	IdleReadTimeout time.Duration
+
+	TLS TLSOptions
+
+	// Proxy is the URL of an http, https or socks5 proxy, with optional
+	// credentials. When empty the proxy environment variables are used.
+	Proxy string
+}
+
+// TLSOptions configures how the server certificate is verified and which
+// client certificate is presented.
+type TLSOptions struct {
+	// CAFile is a PEM bundle of certificate authorities trusted on top of the
+	// system ones.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key used for
+	// mutual TLS.
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool
+}
+
+// Config builds the TLS configuration, or returns nil when the defaults are
+// enough.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if o == (TLSOptions{}) {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		ConnectTimeout:        10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleReadTimeout:       time.Minute,
+	}
+}
+
+// NewHTTPClient returns an HTTP client configured with the given options.
+func NewHTTPClient(opts TransportOptions) (*http.Client, error) {
+	tlsConfig, err := opts.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy, err = opts.proxy()
+	if err != nil {
+		return nil, err
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil || opts.IdleReadTimeout == 0 {
+			return conn, err
+		}
+		return &idleConn{conn, opts.IdleReadTimeout}, nil
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func (o TransportOptions) proxy() (func(*http.Request) (*url.URL, error), error) {
+	if o.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(o.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// ProxyFor returns the proxy requests to target go through, nil meaning they
+// are sent directly.
+func (o TransportOptions) ProxyFor(target string) (*url.URL, error) {
+	proxy, err := o.proxy()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return proxy(req)
+}
+
+// idleConn fails reads that do not receive any data before the timeout.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,18 @@
+package jellyfin
+
+import (
+	"context"
+	"net/url"
+)
+
+type User struct {
+	Name,
+	Id string
+}
+
+// User fetches the user the client is acting as.
+func (c *Client) User(ctx context.Context) (User, error) {
+	var user User
+	err := c.getJSON(ctx, "/Users/"+url.PathEscape(c.userId()), nil, &user)
+	return user, err
+}
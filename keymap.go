@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyMap holds every key binding of the application. A binding key can be a
+// sequence of two keys separated by a space, like "g g".
+type keyMap struct {
+	ForceQuit    key.Binding
+	Quit         key.Binding
+	Focus        key.Binding
+	Back         key.Binding
+	Up           key.Binding
+	Down         key.Binding
+	Left         key.Binding
+	Right        key.Binding
+	Top          key.Binding
+	Bottom       key.Binding
+	PageUp       key.Binding
+	PageDown     key.Binding
+	HalfPageUp   key.Binding
+	HalfPageDown key.Binding
+	Filter       key.Binding
+	Select       key.Binding
+	Details      key.Binding
+	Rule         key.Binding
+	Remove       key.Binding
+	Toggle       key.Binding
+	Press        key.Binding
+	Confirm      key.Binding
+	Cancel       key.Binding
+	Help         key.Binding
+	Footer       key.Binding
+	ExportCSV    key.Binding
+	ExportJSON   key.Binding
+	MoveUp       key.Binding
+	MoveDown     key.Binding
+	Pin          key.Binding
+	PriorityUp   key.Binding
+	PriorityDown key.Binding
+	Pause        key.Binding
+	PauseAll     key.Binding
+}
+
+var keys = defaultKeyMap()
+
+// bind creates a binding whose help is generated from its keys.
+func bind(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(helpKeys(keys), desc))
+}
+
+func helpKeys(keys []string) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		switch k {
+		case " ":
+			names[i] = "space"
+		case "up":
+			names[i] = "↑"
+		case "down":
+			names[i] = "↓"
+		case "left":
+			names[i] = "←"
+		case "right":
+			names[i] = "→"
+		default:
+			names[i] = strings.ReplaceAll(k, " ", "")
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+func defaultKeyMap() keyMap {
+	return keyMap{
+		ForceQuit:    bind("quit", "ctrl+c"),
+		Quit:         bind("quit", "q", "esc"),
+		Focus:        bind("switch focus", "tab"),
+		Back:         bind("back", "esc", "q"),
+		Up:           bind("up", "up", "k"),
+		Down:         bind("down", "down", "j"),
+		Left:         bind("left", "left"),
+		Right:        bind("right", "right"),
+		Top:          bind("go to start", "home", "g"),
+		Bottom:       bind("go to end", "end", "G"),
+		PageUp:       bind("prev page", "pgup", "b", "u"),
+		PageDown:     bind("next page", "pgdown", "f", "d"),
+		HalfPageUp:   bind("half page up", "ctrl+u"),
+		HalfPageDown: bind("half page down", "ctrl+d"),
+		Filter:       bind("filter", "/"),
+		Select:       bind("select", "enter", " "),
+		Details:      bind("details", "i"),
+		Rule:         bind("next unwatched rule", "n"),
+		Remove:       bind("remove download", "r"),
+		Toggle:       bind("start/cancel download", "enter"),
+		Press:        bind("press", "enter"),
+		Confirm:      bind("confirm", "enter"),
+		Cancel:       bind("cancel", "esc"),
+		Help:         bind("help", "?", "f1"),
+		Footer:       bind("toggle help footer", "H"),
+		ExportCSV:    bind("export as CSV", "e"),
+		ExportJSON:   bind("export as JSON", "E"),
+		MoveUp:       bind("move up the queue", "K"),
+		MoveDown:     bind("move down the queue", "J"),
+		Pin:          bind("pin to the top", "t"),
+		PriorityUp:   bind("raise priority", "+"),
+		PriorityDown: bind("lower priority", "-"),
+		Pause:        bind("pause/resume download", "p"),
+		PauseAll:     bind("pause/resume queue", "P"),
+	}
+}
+
+// vimKeyMap moves with hjkl and jumps to the start with gg.
+func vimKeyMap() keyMap {
+	k := defaultKeyMap()
+	k.Left = bind("left", "left", "h")
+	k.Right = bind("right", "right", "l")
+	k.Top = bind("go to start", "home", "g g")
+	k.PageUp = bind("prev page", "pgup", "b")
+	k.PageDown = bind("next page", "pgdown", "f")
+	return k
+}
+
+// bindings returns the bindings by their name in the config.
+func (k *keyMap) bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"forceQuit":    &k.ForceQuit,
+		"quit":         &k.Quit,
+		"focus":        &k.Focus,
+		"back":         &k.Back,
+		"up":           &k.Up,
+		"down":         &k.Down,
+		"left":         &k.Left,
+		"right":        &k.Right,
+		"top":          &k.Top,
+		"bottom":       &k.Bottom,
+		"pageUp":       &k.PageUp,
+		"pageDown":     &k.PageDown,
+		"halfPageUp":   &k.HalfPageUp,
+		"halfPageDown": &k.HalfPageDown,
+		"filter":       &k.Filter,
+		"select":       &k.Select,
+		"details":      &k.Details,
+		"rule":         &k.Rule,
+		"remove":       &k.Remove,
+		"toggle":       &k.Toggle,
+		"press":        &k.Press,
+		"confirm":      &k.Confirm,
+		"cancel":       &k.Cancel,
+		"help":         &k.Help,
+		"footer":       &k.Footer,
+		"exportCSV":    &k.ExportCSV,
+		"exportJSON":   &k.ExportJSON,
+		"moveUp":       &k.MoveUp,
+		"moveDown":     &k.MoveDown,
+		"pin":          &k.Pin,
+		"priorityUp":   &k.PriorityUp,
+		"priorityDown": &k.PriorityDown,
+		"pause":        &k.Pause,
+		"pauseAll":     &k.PauseAll,
+	}
+}
+
+// contexts returns the groups of bindings which are active at the same time,
+// and so must not share a key. The text inputs only see the keys which do not
+// type a character.
+func (k keyMap) contexts() map[string][]string {
+	navigation := []string{"up", "down", "top", "bottom", "pageUp", "pageDown", "halfPageUp", "halfPageDown", "filter"}
+	return map[string][]string{
+		"browser":     append([]string{"select", "details", "rule", "remove", "left", "right", "focus", "help", "footer", "quit", "forceQuit"}, navigation...),
+		"bottombar":   {"left", "right", "press", "focus", "help", "footer", "forceQuit"},
+		"input":       {"confirm", "cancel", "help", "forceQuit"},
+		"filter":      {"confirm", "cancel", "help", "forceQuit"},
+		"downloads":   append([]string{"toggle", "remove", "back", "help", "footer", "forceQuit", "pause", "pauseAll", "moveUp", "moveDown", "pin", "priorityUp", "priorityDown"}, navigation...),
+		"history":     append([]string{"exportCSV", "exportJSON", "back", "help", "footer", "forceQuit"}, navigation...),
+		"diagnostics": {"back", "help", "footer", "forceQuit"},
+	}
+}
+
+// newKeyMap builds the key map from the preset and the bindings overridden in
+// the config.
+func newKeyMap(conf KeysConfig) (keyMap, error) {
+	var k keyMap
+	switch conf.Preset {
+	case "", "default":
+		k = defaultKeyMap()
+	case "vim":
+		k = vimKeyMap()
+	default:
+		return defaultKeyMap(), fmt.Errorf("unknown key preset %q", conf.Preset)
+	}
+
+	bindings := k.bindings()
+	for name, values := range conf.Bindings {
+		b, ok := bindings[name]
+		if !ok {
+			return defaultKeyMap(), fmt.Errorf("unknown action %q", name)
+		}
+		keys := make([]string, len(values))
+		for i, v := range values {
+			if len(strings.Fields(v)) > 2 {
+				return defaultKeyMap(), fmt.Errorf("%s: %q has more than two keys", name, v)
+			}
+			keys[i] = v
+			if v == "space" {
+				keys[i] = " "
+			}
+		}
+		*b = bind(b.Help().Desc, keys...)
+	}
+	return k, k.validate()
+}
+
+// validate reports the keys bound to several actions of the same context, and
+// the single keys shadowing a sequence starting with them.
+func (k keyMap) validate() error {
+	bindings := k.bindings()
+	var conflicts []string
+	for context, names := range k.contexts() {
+		used := make(map[string]string)
+		for _, name := range names {
+			for _, v := range bindings[name].Keys() {
+				if other, ok := used[v]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("%q is bound to both %s and %s in %s", helpKeys([]string{v}), other, name, context))
+				}
+				used[v] = name
+			}
+		}
+		for v, name := range used {
+			if fields := strings.Fields(v); len(fields) == 2 {
+				if other, ok := used[fields[0]]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("%q of %s shadows %q of %s in %s", fields[0], other, helpKeys([]string{v}), name, context))
+				}
+			}
+		}
+	}
+	if len(conflicts) != 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("conflicting key bindings: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// isPrefix tells if s is the first key of a sequence.
+func (k keyMap) isPrefix(s string) bool {
+	for _, b := range k.bindings() {
+		for _, v := range b.Keys() {
+			if strings.HasPrefix(v, s+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sequence merges a pending key with the next one when they form a bound
+// sequence. It returns false when the key is swallowed, waiting for the next.
+func (k keyMap) sequence(pending *string, msg tea.KeyMsg) (tea.KeyMsg, bool) {
+	s := msg.String()
+	if *pending != "" {
+		seq := *pending + " " + s
+		*pending = ""
+		for _, b := range k.bindings() {
+			for _, v := range b.Keys() {
+				if v == seq {
+					return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(seq)}, true
+				}
+			}
+		}
+	}
+	if k.isPrefix(s) {
+		*pending = s
+		return msg, false
+	}
+	return msg, true
+}
+
+// listKeyMap makes a list follow the key map.
+func (k keyMap) listKeyMap(quit bool) list.KeyMap {
+	l := list.DefaultKeyMap()
+	l.CursorUp = k.Up
+	l.CursorDown = k.Down
+	l.GoToStart = k.Top
+	l.GoToEnd = k.Bottom
+	l.PrevPage = k.PageUp
+	l.NextPage = k.PageDown
+	l.Filter = k.Filter
+	l.AcceptWhileFiltering = k.Confirm
+	l.CancelWhileFiltering = k.Cancel
+	l.ForceQuit = k.ForceQuit
+	l.Quit = k.Quit
+	l.Quit.SetEnabled(quit)
+	l.ShowFullHelp.SetEnabled(false)
+	l.CloseFullHelp.SetEnabled(false)
+	return l
+}
+
+// moveHalfPage moves the cursor of a list by half of its page.
+func moveHalfPage(l *list.Model, down bool) {
+	for i := 0; i < max(l.Paginator.PerPage/2, 1); i++ {
+		if down {
+			l.CursorDown()
+		} else {
+			l.CursorUp()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestKeyMap(t *testing.T) {
+	k, err := newKeyMap(KeysConfig{Preset: "vim", Bindings: map[string][]string{"remove": {"x"}, "select": {"space"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Matches(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}, k.Remove) {
+		t.Error("remove should be bound to x")
+	}
+	if !key.Matches(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}, k.Select) {
+		t.Error("select should be bound to space")
+	}
+	if got := k.Select.Help().Key; got != "space" {
+		t.Errorf("select help = %q", got)
+	}
+	if got := k.Top.Help().Key; got != "home/gg" {
+		t.Errorf("top help = %q", got)
+	}
+
+	if _, err := newKeyMap(KeysConfig{Bindings: map[string][]string{"details": {"r"}}}); err == nil {
+		t.Error("binding details to the remove key should conflict")
+	}
+	if _, err := newKeyMap(KeysConfig{Preset: "vim", Bindings: map[string][]string{"details": {"g"}}}); err == nil {
+		t.Error("g should conflict with the gg sequence")
+	}
+	if _, err := newKeyMap(KeysConfig{Bindings: map[string][]string{"unknown": {"x"}}}); err == nil {
+		t.Error("unknown actions should be reported")
+	}
+}
+
+func TestKeySequence(t *testing.T) {
+	k := vimKeyMap()
+	var pending string
+	g := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")}
+	if _, ok := k.sequence(&pending, g); ok {
+		t.Fatal("g should wait for the next key")
+	}
+	msg, ok := k.sequence(&pending, g)
+	if !ok || !key.Matches(msg, k.Top) {
+		t.Errorf("gg should go to the start, got %q", msg.String())
+	}
+
+	k.sequence(&pending, g)
+	if msg, ok := k.sequence(&pending, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")}); !ok || !key.Matches(msg, k.Down) {
+		t.Errorf("an unbound sequence should pass the second key, got %q", msg.String())
+	}
+}
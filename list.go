@@ -1,24 +1,22 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"strconv"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-)
 
-var docStyle = lipgloss.NewStyle().Margin(1, 2).
-	Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("62"))
-var unSelect = lipgloss.NewStyle().Margin(0, 1).Foreground(lipgloss.Color("220"))
-var selectedItem = lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
-var downloadedItem = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
-var classicItem = lipgloss.NewStyle()
+	"jellyfindl/jellyfin"
+)
 
 type item struct {
-	title, desc, id string
-	isFolder        bool
+	title, desc, id, itemType string
+	isFolder                  bool
 }
 
 type reloadItemsMsg struct{}
@@ -28,85 +26,164 @@ func (i item) Description() string { return i.desc }
 func (i item) FilterValue() string { return i.title }
 
 type jellyfinViewModel struct {
-	lists      []*list.Model
-	focused    int
-	isActive   bool
-	loaded     map[string][]JellyfinItem
-	width      int
-	height     int
-	config     *Config
-	loadingMsg string
-	requestId  int
+	lists       []*list.Model
+	focused     int
+	isActive    bool
+	loaded      map[string][]jellyfin.Item
+	totals      map[string]int
+	loadingPage map[string]bool
+	failedPage  map[string]int
+	parents     []string
+	width       int
+	height      int
+	config      *Config
+	loadingMsg  string
+	requestId   int
+	showDetails bool
+	details     map[string]jellyfin.Item
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	// Set on the snapshots the commands work on
+	cursors []int
+	fetched map[string]jellyfin.Response
 }
 
 func (m *jellyfinViewModel) InitModel() {
-	m.loaded = make(map[string][]JellyfinItem)
+	m.loaded = make(map[string][]jellyfin.Item)
+	m.totals = make(map[string]int)
+	m.loadingPage = make(map[string]bool)
+	m.failedPage = make(map[string]int)
+	m.details = make(map[string]jellyfin.Item)
 	m.loadingMsg = "Loading..."
 }
 
 /* Bubble tea */
 func (m jellyfinViewModel) Init() tea.Cmd {
-	return m.UpdateItems
+	return m.snapshot().UpdateItems
 }
 
 func (m jellyfinViewModel) Update(msg tea.Msg) (jellyfinViewModel, tea.Cmd) {
 	var cmds = make([]tea.Cmd, 0)
+	var moved bool
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "right":
+		switch {
+		case m.isFiltering():
+		case key.Matches(msg, keys.Right):
 			m.focused++
 			if m.focused == len(m.lists) {
 				m.focused = 0
 			}
-			return m, nil
-		case "left":
+			return m, m.detailsCmd()
+		case key.Matches(msg, keys.Left):
 			m.focused--
 			if m.focused == -1 {
 				m.focused = len(m.lists) - 1
 			}
-			return m, nil
-		case "up", "down":
-			m.requestId++
-			cmds = append(cmds, m.UpdateItems)
-		case "enter", "space":
-			cmds = append(cmds, m.SelectUnSelect)
-		case "r":
+			return m, m.detailsCmd()
+		case key.Matches(msg, keys.Details):
+			m.showDetails = !m.showDetails
+			setListsSize(m.lists, m.listsWidth(), m.height)
+			return m, m.detailsCmd()
+		case key.Matches(msg, keys.HalfPageUp, keys.HalfPageDown):
+			if len(m.lists) != 0 {
+				moveHalfPage(m.lists[m.focused], key.Matches(msg, keys.HalfPageDown))
+			}
+			m.retryPage()
+			return m, tea.Batch(m.refresh(), m.loadMoreCmd())
+		case key.Matches(msg, keys.Up, keys.Down, keys.Top, keys.Bottom, keys.PageUp, keys.PageDown):
+			// Refreshed once the list moved the cursor
+			moved = true
+			m.retryPage()
+		case key.Matches(msg, keys.Select):
+			cmds = append(cmds, m.selectCmd())
+		case key.Matches(msg, keys.Rule):
+			it := m.lists[m.focused].SelectedItem().(item)
+			if it.itemType == "Series" {
+				return m, sendMessage(askRuleMsg{it.id, m.itemName(it.id)})
+			}
+		case key.Matches(msg, keys.Remove):
 			id := m.lists[m.focused].SelectedItem().(item).id
 			path, ok := m.config.Downloaded[id]
 			if ok {
 				os.Remove(path)
 				delete(m.config.Downloaded, id)
 				writeConfig(*m.config)
-				return m, m.UpdateItems
+				return m, m.refresh()
 			}
 		}
 
 	case tea.WindowSizeMsg: //Custom send by the main model
 		m.width = msg.Width
 		m.height = msg.Height
-		setListsSize(m.lists, msg.Width, msg.Height)
+		setListsSize(m.lists, m.listsWidth(), msg.Height)
 		docStyle = docStyle.Height(msg.Height - docStyle.GetVerticalFrameSize())
 		docStyle = docStyle.Width(msg.Width / 5)
 
 	case itemsMsg:
+		m.merge(msg.fetched)
 		if m.requestId == msg.requestId {
-			return m.applyItems(msg.lists)
+			var cmd tea.Cmd
+			m.parents = msg.parents
+			m, cmd = m.applyItems(msg.lists)
+			cmds = append(cmds, cmd, m.detailsCmd(), m.loadMoreCmd())
+			for _, parentId := range msg.revalidate {
+				cmds = append(cmds, revalidateCmd(parentId, m.config))
+			}
+			return m, tea.Batch(cmds...)
+		}
+	case pageLoadedMsg:
+		delete(m.loadingPage, msg.parentId)
+		if len(msg.res.Items) == 0 && msg.start < m.totals[msg.parentId] {
+			// Not requested again until the user moves in the column
+			m.failedPage[msg.parentId] = msg.start
+			return m, nil
+		}
+		if len(m.loaded[msg.parentId]) == msg.start && len(msg.res.Items) != 0 {
+			m.loaded[msg.parentId] = append(m.loaded[msg.parentId], msg.res.Items...)
+			m.totals[msg.parentId] = msg.res.TotalRecordCount
+			m.config.cache.put(msg.parentId, m.loaded[msg.parentId], msg.res.TotalRecordCount)
 		}
+		return m, m.refresh()
+	case revalidatedMsg:
+		if len(msg.res.Items) == 0 || msg.offline {
+			return m, nil
+		}
+		if isSameItems(m.loaded[msg.parentId], msg.res.Items) && m.totals[msg.parentId] == msg.res.TotalRecordCount {
+			// Still valid, with the pages loaded since
+			m.config.cache.put(msg.parentId, m.loaded[msg.parentId], msg.res.TotalRecordCount)
+			return m, nil
+		}
+		m.config.cache.put(msg.parentId, msg.res.Items, msg.res.TotalRecordCount)
+		m.loaded[msg.parentId] = msg.res.Items
+		m.totals[msg.parentId] = msg.res.TotalRecordCount
+		return m, m.refresh()
+	case mouseMsg:
+		return m.updateMouse(msg)
+	case detailsMsg:
+		m.details[msg.item.Id] = msg.item
+		return m, nil
 	case selectedMsg:
+		m.merge(msg.fetched)
 		writeConfig(*m.config)
-		m.requestId++
-		cmds = append(cmds, m.UpdateItems)
+		cmds = append(cmds, m.refresh())
 	case reloadItemsMsg:
 		m.lists = make([]*list.Model, 0)
 		m.InitModel()
-		return m, m.UpdateItems
+		m.config.cache.flush()
+		m.config.cache = loadCache(m.config)
+		m.config.client, m.config.clientErr = m.config.newClient()
+		return m, m.refresh()
 	}
 
 	if len(m.lists) != 0 {
 		ml, cmd := m.lists[m.focused].Update(msg)
 		m.lists[m.focused] = &ml
-		cmds = append(cmds, cmd)
+		cmds = append(cmds, cmd, m.loadMoreCmd())
+	}
+	if moved {
+		cmds = append(cmds, m.refresh())
 	}
 
 	return m, tea.Batch(cmds...)
@@ -132,16 +209,43 @@ func (m jellyfinViewModel) View() string {
 				l.Styles.Title = unSelect
 				l.View()
 			} else {
-				l.Styles.Title = list.DefaultStyles().Title
+				l.Styles.Title = focusedTitleStyle
 			}
 			views[i] = docStyle.Width(l.Width()).Render(l.View())
 		}
+		if m.showDetails {
+			views = append(views, m.detailsView())
+		}
 		view = lipgloss.JoinHorizontal(lipgloss.Left, views...)
 	}
 	return view
 }
 
+func (m jellyfinViewModel) detailsCmd() tea.Cmd {
+	if !m.showDetails {
+		return nil
+	}
+	return m.UpdateDetails
+}
+
+// itemName returns the name of a loaded item as the server sent it, without
+// the markers and the styling of its title.
+func (m jellyfinViewModel) itemName(id string) string {
+	if m.focused < len(m.parents) {
+		for _, e := range m.loaded[m.parents[m.focused]] {
+			if e.Id == id {
+				return e.Name
+			}
+		}
+	}
+	return id
+}
+
 /* Sizing */
+func (m jellyfinViewModel) listsWidth() int {
+	return m.width - m.detailsWidth()
+}
+
 func setListsSize(lists []*list.Model, width int, height int) {
 	h, v := docStyle.GetFrameSize()
 	var sizeUsed int
@@ -160,10 +264,14 @@ func setListsSize(lists []*list.Model, width int, height int) {
 }
 
 /* Jellyfin Item providers & update */
-func (m *jellyfinViewModel) fillItems(parentId string) []list.Item {
+func (m *jellyfinViewModel) fillItems(ctx context.Context, parentId string) ([]list.Item, bool) {
 	collections, ok := m.loaded[parentId]
+	var stale bool
 	if !ok {
-		collections = getChilds(parentId, m.config).Items
+		collections, stale = m.fetchItems(ctx, parentId)
+		if ctx.Err() != nil {
+			return nil, false
+		}
 	}
 
 	items := make([]list.Item, len(collections))
@@ -174,7 +282,12 @@ func (m *jellyfinViewModel) fillItems(parentId string) []list.Item {
 			name = strconv.Itoa(e.EpisodeNumber) + ". " + name
 		}
 
+		if count, ok := m.config.NextUnwatched[e.Id]; ok {
+			name += " (next " + strconv.Itoa(count) + ")"
+		}
+
 		_, ok := m.config.Downloaded[e.Id]
+		name = itemMarker(m.config.Selected.Contains(e.Id), ok) + name
 		if ok {
 			name = downloadedItem.Render(name)
 		} else if m.config.Selected.Contains(e.Id) {
@@ -182,33 +295,98 @@ func (m *jellyfinViewModel) fillItems(parentId string) []list.Item {
 		} else {
 			name = classicItem.Render(name)
 		}
-		items[i] = item{title: name, id: e.Id, isFolder: e.IsFolder}
+		items[i] = item{title: name, id: e.Id, itemType: e.Type, isFolder: e.IsFolder}
 	}
 	m.loaded[parentId] = collections
-	return items
+	return items, stale
+}
+
+// fetchItems serves the first page of a parent from the on-disk cache, even
+// past its TTL, and from the server when it was never fetched. The second
+// result tells the cached page is stale and must be revalidated.
+func (m *jellyfinViewModel) fetchItems(ctx context.Context, parentId string) ([]jellyfin.Item, bool) {
+	if entry, ok, fresh := m.config.cache.get(parentId); ok && !m.config.cache.isOffline() {
+		m.totals[parentId] = entry.Total
+		m.fetched[parentId] = jellyfin.Response{Items: entry.Items, TotalRecordCount: entry.Total}
+		return entry.Items, !fresh
+	}
+
+	res := getChildsPage(ctx, parentId, 0, m.config)
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	if len(res.Items) != 0 && !m.config.cache.isOffline() {
+		m.config.cache.put(parentId, res.Items, res.TotalRecordCount)
+	}
+	m.totals[parentId] = res.TotalRecordCount
+	m.fetched[parentId] = res
+	return res.Items, false
 }
 
 type itemsMsg struct {
-	requestId int
-	lists     [][]list.Item
+	requestId  int
+	lists      [][]list.Item
+	parents    []string
+	revalidate []string
+	fetched    map[string]jellyfin.Response
+}
+
+// snapshot copies the state a command reads. The commands only fill the maps
+// of their copy and return what they fetched, Update merges it in the model.
+func (m jellyfinViewModel) snapshot() *jellyfinViewModel {
+	s := m
+	s.loaded = make(map[string][]jellyfin.Item, len(m.loaded))
+	for id, items := range m.loaded {
+		s.loaded[id] = items
+	}
+	s.totals = make(map[string]int, len(m.totals))
+	for id, total := range m.totals {
+		s.totals[id] = total
+	}
+	s.cursors = make([]int, len(m.lists))
+	for i, l := range m.lists {
+		s.cursors[i] = l.Index()
+	}
+	s.lists = nil
+	s.fetched = make(map[string]jellyfin.Response)
+	return &s
+}
+
+// merge keeps the items fetched by a command, unless Update loaded more of
+// them meanwhile.
+func (m *jellyfinViewModel) merge(fetched map[string]jellyfin.Response) {
+	for id, res := range fetched {
+		if _, ok := m.loaded[id]; !ok || len(res.Items) > len(m.loaded[id]) {
+			m.loaded[id] = res.Items
+			m.totals[id] = res.TotalRecordCount
+		}
+	}
 }
 
 func (m *jellyfinViewModel) UpdateItems() tea.Msg {
 	var requestId = m.requestId
+	ctx := m.context()
 	var lastParent string
 	var i int
 	var lists [][]list.Item
+	var parents, revalidate []string
 	for {
-		items := m.fillItems(lastParent)
+		items, stale := m.fillItems(ctx, lastParent)
+		if ctx.Err() != nil {
+			return nil
+		}
 		if len(items) == 0 {
 			break
 		}
+		if stale {
+			revalidate = append(revalidate, lastParent)
+		}
 		lists = append(lists, items)
+		parents = append(parents, lastParent)
 
 		var it item
-		if isInside(m.lists, i) {
-			cursor := m.lists[i].Index()
-			if cursor < len(items) {
+		if i < len(m.cursors) {
+			if cursor := m.cursors[i]; cursor < len(items) {
 				it = items[cursor].(item)
 			}
 		} else {
@@ -226,10 +404,72 @@ func (m *jellyfinViewModel) UpdateItems() tea.Msg {
 		}
 	}
 
-	return itemsMsg{requestId, lists}
+	return itemsMsg{requestId, lists, parents, revalidate, m.fetched}
+}
+
+// refresh cancels the requests of the previous refresh, which are now stale,
+// and reloads the columns.
+func (m *jellyfinViewModel) refresh() tea.Cmd {
+	m.requestId++
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	return m.snapshot().UpdateItems
+}
+
+func (m jellyfinViewModel) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+/* Pagination */
+const loadMoreThreshold = 10
+
+type pageLoadedMsg struct {
+	parentId string
+	start    int
+	res      jellyfin.Response
+}
+
+func (m jellyfinViewModel) loadMoreCmd() tea.Cmd {
+	if !isInside(m.lists, m.focused) || m.focused >= len(m.parents) {
+		return nil
+	}
+	parentId := m.parents[m.focused]
+	l := m.lists[m.focused]
+	start := len(m.loaded[parentId])
+	if l.Index() < len(l.Items())-loadMoreThreshold || start >= m.totals[parentId] || m.loadingPage[parentId] {
+		return nil
+	}
+	if failed, ok := m.failedPage[parentId]; ok && failed == start {
+		return nil
+	}
+
+	m.loadingPage[parentId] = true
+	config := m.config
+	return func() tea.Msg {
+		return pageLoadedMsg{parentId, start, getChildsPage(context.Background(), parentId, start, config)}
+	}
+}
+
+// retryPage lets loadMoreCmd request again the page of the focused column that
+// failed.
+func (m jellyfinViewModel) retryPage() {
+	if m.focused < len(m.parents) {
+		delete(m.failedPage, m.parents[m.focused])
+	}
 }
 
-var listTitleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("220"))
+func (m jellyfinViewModel) countTitle(title, parentId string) string {
+	total := m.totals[parentId]
+	if loaded := len(m.loaded[parentId]); loaded < total {
+		return fmt.Sprintf("%s (%d/%d)", title, loaded, total)
+	}
+	return fmt.Sprintf("%s (%d)", title, total)
+}
 
 func (m jellyfinViewModel) applyItems(lists [][]list.Item) (jellyfinViewModel, tea.Cmd) {
 	var cmds []tea.Cmd
@@ -250,37 +490,59 @@ func (m jellyfinViewModel) applyItems(lists [][]list.Item) (jellyfinViewModel, t
 			if active == nil {
 				active = viewLists[i-1].Items()[0]
 			}
-			l.Title = active.(item).title
+			l.Title = m.countTitle(active.(item).title, m.parents[i])
+		} else {
+			l.Title = m.countTitle("Jellyfin", "")
 		}
 		viewLists = append(viewLists, l)
 	}
 	m.lists = viewLists
-	setListsSize(m.lists, m.width, m.height)
+	setListsSize(m.lists, m.listsWidth(), m.height)
 	return m, tea.Batch(cmds...)
 }
 
 /* Selection Handling */
-type selectedMsg struct{}
+type selectedMsg struct {
+	fetched map[string]jellyfin.Response
+}
 
-func (m *jellyfinViewModel) SelectUnSelect() tea.Msg {
-	lFocused := m.lists[m.focused]
-	it := lFocused.SelectedItem().(item)
-	added := m.config.Selected.Toggle(it.id)
-	if it.isFolder {
-		m.forcedSelectUnSelect(it.id, added)
-	}
+// isPseudoFolder reports whether id is a folder made by jellyfindl, which the
+// server does not know and which cannot be selected.
+func isPseudoFolder(id string) bool {
+	return id == playlistsId || id == downloadedId
+}
 
-	return selectedMsg{}
+// selectCmd toggles the highlighted item, and the content of a folder.
+func (m jellyfinViewModel) selectCmd() tea.Cmd {
+	it := m.lists[m.focused].SelectedItem().(item)
+	if isPseudoFolder(it.id) {
+		return sendMessage(infoMsg{it.title + " cannot be selected"})
+	}
+	s := m.snapshot()
+	return func() tea.Msg {
+		added := s.config.Selected.Toggle(it.id)
+		if it.isFolder {
+			s.forcedSelectUnSelect(it.id, added)
+		}
+		return selectedMsg{s.fetched}
+	}
 }
 
 func (m *jellyfinViewModel) forcedSelectUnSelect(it string, added bool) {
 	collections, ok := m.loaded[it]
-	if !ok {
-		collections = getChilds(it, m.config).Items
+	if !ok || len(collections) < m.totals[it] {
+		res := getChilds(context.Background(), it, m.config)
+		collections = res.Items
 		m.loaded[it] = collections
+		m.totals[it] = res.TotalRecordCount
+		m.fetched[it] = res
+		m.config.cache.put(it, collections, res.TotalRecordCount)
 	}
 
 	for _, child := range collections {
+		if isPseudoFolder(child.Id) {
+			continue
+		}
 		if added {
 			m.config.Selected.Add(child.Id)
 		} else {
@@ -293,15 +555,19 @@ func (m *jellyfinViewModel) forcedSelectUnSelect(it string, added bool) {
 }
 
 /* List model creation */
-func createList(items []list.Item, desc bool) *list.Model {
+func listDelegate(desc bool) list.DefaultDelegate {
 	delegate := list.DefaultDelegate{
 		ShowDescription: desc,
 		Styles:          list.NewDefaultItemStyles(),
 	}
 	delegate.SetHeight(2)
 	delegate.SetSpacing(1)
+	return delegate
+}
 
-	list := list.New(items, delegate, 10, 10)
+func createList(items []list.Item, desc bool) *list.Model {
+	list := list.New(items, listDelegate(desc), 10, 10)
+	list.KeyMap = keys.listKeyMap(true)
 	list.SetShowHelp(false)
 	return &list
 }
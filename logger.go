@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = map[logLevel]string{
+	levelDebug: "debug",
+	levelInfo:  "info",
+	levelWarn:  "warn",
+	levelError: "error",
+}
+
+func parseLogLevel(name string) (logLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return levelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// fileLogger writes leveled logfmt lines, redacting the registered secrets.
+type fileLogger struct {
+	mu      sync.Mutex
+	out     io.Writer
+	level   logLevel
+	secrets []string
+}
+
+var logger = &fileLogger{out: io.Discard, level: levelInfo}
+
+func getStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "jellyfindl")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		configFolder, err := os.UserConfigDir()
+		checkError(err)
+		return filepath.Join(configFolder, "jellyfindl")
+	}
+	return filepath.Join(home, ".local", "state", "jellyfindl")
+}
+
+func openLogger(path, level string) (*fileLogger, error) {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = filepath.Join(getStateDir(), "jellyfindl.log")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLogger{out: file, level: l}, nil
+}
+
+// addSecret makes the logger replace every occurrence of secret.
+func (l *fileLogger) addSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, v := range l.secrets {
+		if v == secret {
+			return
+		}
+	}
+	l.secrets = append(l.secrets, secret)
+}
+
+func (l *fileLogger) redact(s string) string {
+	for _, secret := range l.secrets {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	return s
+}
+
+func (l *fileLogger) log(level logLevel, msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("time=" + time.Now().Format(time.RFC3339))
+	b.WriteString(" level=" + logLevelNames[level])
+	b.WriteString(" msg=" + formatLogValue(l.redact(msg)))
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		b.WriteString(fmt.Sprintf(" %v=%s", keyvals[i], formatLogValue(l.redact(logValue(keyvals[i+1])))))
+	}
+	b.WriteString("\n")
+	io.WriteString(l.out, b.String())
+}
+
+func logValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case error:
+		return v.Error()
+	case *url.URL:
+		return redactURL(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatLogValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \"=\n\t") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// redactURL hides the credentials a URL can hold.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	values := redacted.Query()
+	for key := range values {
+		switch strings.ToLower(key) {
+		case "api_key", "apikey", "token":
+			values.Set(key, "[REDACTED]")
+		}
+	}
+	redacted.RawQuery = values.Encode()
+	return redacted.Redacted()
+}
+
+func (l *fileLogger) Debug(msg string, keyvals ...interface{}) {
+	l.log(levelDebug, msg, keyvals...)
+}
+
+func (l *fileLogger) Info(msg string, keyvals ...interface{}) {
+	l.log(levelInfo, msg, keyvals...)
+}
+
+func (l *fileLogger) Warn(msg string, keyvals ...interface{}) {
+	l.log(levelWarn, msg, keyvals...)
+}
+
+func (l *fileLogger) Error(msg string, keyvals ...interface{}) {
+	l.log(levelError, msg, keyvals...)
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"runtime/debug"
 
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/jessevdk/go-flags"
@@ -13,7 +16,7 @@ import (
 type focus int
 
 const (
-	jellyfin focus = iota
+	browser focus = iota
 	bottombar
 )
 
@@ -22,18 +25,25 @@ type screen int
 const (
 	mainScreen screen = iota
 	downloadScreen
+	diagnosticsScreen
+	historyScreen
 )
 
 type model struct {
 	jellyfinViewModel jellyfinViewModel
 	bottombarModel    bottombarModel
 	downloadModel     downloadModel
+	diagnosticsModel  diagnosticsModel
+	historyModel      historyModel
 	focus             focus
 	currentScreen     screen
 	width             int
 	height            int
 	config            *Config
 	lastRequest       int
+	pendingKey        string
+	showHelp          bool
+	lastClick         click
 }
 
 type infoMsg struct {
@@ -42,15 +52,36 @@ type infoMsg struct {
 
 func (m *model) InitModel() {
 	m.config = getConfig()
+	m.config.cache = loadCache(m.config)
+	m.config.client, m.config.clientErr = m.config.newClient()
+	if m.config.clientErr != nil {
+		logger.Error("invalid connection settings", "error", m.config.clientErr)
+	}
+	var keysErr error
+	keys, keysErr = newKeyMap(m.config.Keys)
+	t, themeErr := loadTheme(m.config.Theme)
+	applyTheme(t)
 	m.jellyfinViewModel.config = m.config
 	m.jellyfinViewModel.InitModel()
 	m.jellyfinViewModel.isActive = true
 	m.bottombarModel.InitModel()
 	m.bottombarModel.config = m.config
+	if keysErr != nil {
+		logger.Error("invalid key bindings", "error", keysErr)
+		m.bottombarModel.info = errorStyle.Render("Invalid key bindings, using the defaults: " + keysErr.Error())
+	}
+	if err := m.config.Schedule.validate(); err != nil {
+		logger.Error("invalid schedule", "error", err)
+		m.bottombarModel.info = errorStyle.Render("Invalid download schedule: " + err.Error())
+	}
+	if themeErr != nil {
+		logger.Error("invalid theme", "error", themeErr)
+		m.bottombarModel.info = errorStyle.Render("Invalid theme, using the default one: " + themeErr.Error())
+	}
 
 	if args.Download {
 		m.currentScreen = downloadScreen
-		m.focus = jellyfin
+		m.focus = browser
 		m.downloadModel = downloadModel{width: m.width, height: m.height, config: m.config}
 		m.downloadModel.InitModel()
 	}
@@ -69,34 +100,58 @@ func (m model) Init() tea.Cmd {
 	if args.Download {
 		return tea.Batch(m.jellyfinViewModel.Init(), m.downloadModel.Init())
 	}
-	return m.jellyfinViewModel.Init()
+	if args.Offline {
+		return tea.Batch(m.jellyfinViewModel.Init(), sendMessage(infoMsg{"Offline mode, browsing from the cache"}))
+	}
+	if len(m.config.NextUnwatched) != 0 {
+		// The items and the summary are loaded once the rules changed the selection
+		return applyRulesCmd(m.config)
+	}
+	return tea.Batch(m.jellyfinViewModel.Init(), computeSelectionSummary(m.config))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case rulesAppliedMsg:
+		if ruleChanges(msg).apply(m.config.Selected) {
+			writeConfig(*m.config)
+		}
+		return m, tea.Batch(m.jellyfinViewModel.refresh(), computeSelectionSummary(m.config))
+	case tea.KeyMsg:
+		if m, cmd, ok := m.updateHelp(msg); ok {
+			return m, cmd
+		}
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
+	}
 	if m.currentScreen == mainScreen {
 		var cmds = make([]tea.Cmd, 0)
 		shouldBePassed := true
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			switch msg.String() {
-			case "ctrl+c":
+			switch {
+			case key.Matches(msg, keys.ForceQuit):
 				return m, tea.Quit
-			case "tab":
-				if m.focus == jellyfin {
+			case key.Matches(msg, keys.Focus):
+				if m.focus == browser {
 					m.focus = bottombar
 					m.jellyfinViewModel.isActive = false
 					m.bottombarModel.isActive = true
 				} else {
 					m.jellyfinViewModel.isActive = true
 					m.bottombarModel.isActive = false
-					m.focus = jellyfin
+					m.focus = browser
 				}
 				return m, nil
 			default:
 				switch m.focus {
-				case jellyfin:
-					//var cmd tea.Cmd
-					//_ /*m.jellyfinViewModel*/, cmd := m.jellyfinViewModel.Update(msg)
+				case browser:
+					if !m.jellyfinViewModel.isFiltering() {
+						var ok bool
+						if msg, ok = keys.sequence(&m.pendingKey, msg); !ok {
+							return m, nil
+						}
+					}
 					return m.callJellyfinUpdate(msg)
 				case bottombar:
 					return m.callBottombarUpdate(msg)
@@ -106,14 +161,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			//h, v := docStyle.GetFrameSize()
 			m.width = msg.Width
 			m.height = msg.Height
-			jModel, jCmds := m.jellyfinViewModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height - 2})
+			jModel, jCmds := m.jellyfinViewModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height - 2 - m.footerHeight()})
 			cmds = append(cmds, jCmds)
 			m.jellyfinViewModel = jModel
 
 			shouldBePassed = false
 
-		case infoMsg:
+		case infoMsg, selectionSummaryMsg:
 			return m.callBottombarUpdate(msg)
+		case certificateErrorMsg:
+			m.jellyfinViewModel.loadingMsg = errorStyle.Render(string(msg))
+			return m.callBottombarUpdate(msg)
+		case transportErrorMsg:
+			m.jellyfinViewModel.loadingMsg = errorStyle.Render(string(msg))
+			return m.callBottombarUpdate(msg)
+		case selectedMsg:
+			var cmd tea.Cmd
+			m.jellyfinViewModel, cmd = m.jellyfinViewModel.Update(msg)
+			return m, tea.Batch(cmd, computeSelectionSummary(m.config))
 		case incorrectAPIEndPointMsg, incorrectUserIdMsg, incorrectAPIKeyMsg:
 			var str string
 			switch msg := msg.(type) {
@@ -131,20 +196,29 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.bottombarModel.buttonsActive = false
 			m.jellyfinViewModel.isActive = false
 			return m.callBottombarUpdate(msg)
+		case askRuleMsg:
+			m.focus = bottombar
+			m.bottombarModel.isActive = true
+			m.jellyfinViewModel.isActive = false
+			return m.callBottombarUpdate(msg)
 		case reloadItemsMsg:
-			m.focus = jellyfin
+			m.focus = browser
 			m.bottombarModel.isActive = false
 			m.jellyfinViewModel.isActive = true
 			m.callJellyfinUpdate(msg)
 		default:
-			if m.focus == jellyfin {
+			if m.focus == browser {
 				return m.callJellyfinUpdate(msg)
 			} else {
 				return m.callBottombarUpdate(msg)
 			}
 		case inputDoneMsg:
 			if msg.id != DownloadLocation {
-				m.focus = jellyfin
+				m.focus = browser
+			}
+			if msg.id == NextUnwatched {
+				m.bottombarModel.isActive = false
+				m.jellyfinViewModel.isActive = true
 			}
 			return m.callBottombarUpdate(msg)
 		case inputCancelMsg:
@@ -157,10 +231,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch ButtonId(msg) {
 			case DownloadAll:
 				m.currentScreen = downloadScreen
-				m.focus = jellyfin
-				m.downloadModel = downloadModel{width: m.width, height: m.height, config: m.config}
+				m.focus = browser
+				m.downloadModel = downloadModel{width: m.width, height: m.height - m.footerHeight(), config: m.config}
 				m.downloadModel.InitModel()
 				return m, m.downloadModel.Init()
+			case Diagnostics:
+				m.currentScreen = diagnosticsScreen
+				m.diagnosticsModel = diagnosticsModel{config: m.config}
+				return m, nil
+			case History:
+				m.currentScreen = historyScreen
+				m.historyModel = historyModel{width: m.width, height: m.height - m.footerHeight(), config: m.config}
+				m.historyModel.InitModel()
+				return m, m.historyModel.Init()
 			default:
 				return m.callBottombarUpdate(msg)
 			}
@@ -173,24 +256,76 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		return m, tea.Batch(cmds...)
+	} else if m.currentScreen == diagnosticsScreen {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, keys.ForceQuit):
+				return m, tea.Quit
+			case key.Matches(msg, keys.Back):
+				m.currentScreen = mainScreen
+			}
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			m.jellyfinViewModel, _ = m.jellyfinViewModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height - 2 - m.footerHeight()})
+		default:
+			return m.callJellyfinUpdate(msg)
+		}
+		return m, nil
+	} else if m.currentScreen == historyScreen {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			if m.historyModel.list.SettingFilter() {
+				return m.callHistoryUpdate(msg)
+			}
+			var ok bool
+			if msg, ok = keys.sequence(&m.pendingKey, msg); !ok {
+				return m, nil
+			}
+			switch {
+			case key.Matches(msg, keys.ForceQuit):
+				return m, tea.Quit
+			case key.Matches(msg, keys.Back):
+				m.currentScreen = mainScreen
+				return m, nil
+			}
+			return m.callHistoryUpdate(msg)
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			m.jellyfinViewModel, _ = m.jellyfinViewModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height - 2 - m.footerHeight()})
+			return m.callHistoryUpdate(tea.WindowSizeMsg{Width: m.width, Height: m.height - m.footerHeight()})
+		case historyLoadedMsg, list.FilterMatchesMsg:
+			return m.callHistoryUpdate(msg)
+		default:
+			return m.callJellyfinUpdate(msg)
+		}
 	} else {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			switch msg.String() {
-			case "ctrl+c", "esc":
+			if m.downloadModel.list.SettingFilter() {
+				return m.callDownloadUpdate(msg)
+			}
+			var ok bool
+			if msg, ok = keys.sequence(&m.pendingKey, msg); !ok {
+				return m, nil
+			}
+			switch {
+			case key.Matches(msg, keys.ForceQuit, keys.Back):
 				m.downloadModel.CancelAll()
 				m.currentScreen = mainScreen
 				m.jellyfinViewModel.isActive = true
 				m.bottombarModel.isActive = false
-				return m, m.jellyfinViewModel.UpdateItems
+				return m, tea.Batch(m.jellyfinViewModel.refresh(), computeSelectionSummary(m.config))
 			default:
 				return m.callDownloadUpdate(msg)
 			}
 		case tea.WindowSizeMsg:
 			m.width = msg.Width
 			m.height = msg.Height
-			m.jellyfinViewModel, _ = m.jellyfinViewModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height - 2})
-			return m.callDownloadUpdate(msg)
+			m.jellyfinViewModel, _ = m.jellyfinViewModel.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height - 2 - m.footerHeight()})
+			return m.callDownloadUpdate(tea.WindowSizeMsg{Width: m.width, Height: m.height - m.footerHeight()})
 		default:
 			return m.callDownloadUpdate(msg)
 		}
@@ -198,23 +333,54 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
+	if m.showHelp {
+		return m.helpView()
+	}
+
+	var view string
 	if m.currentScreen == mainScreen {
 		jellyfinView := m.jellyfinViewModel.View()
 		bottombarView := m.bottombarModel.View()
 
-		return lipgloss.JoinVertical(lipgloss.Left, jellyfinView, bottombarView)
+		view = lipgloss.JoinVertical(lipgloss.Left, jellyfinView, bottombarView)
+	} else if m.currentScreen == diagnosticsScreen {
+		view = m.diagnosticsModel.View()
+	} else if m.currentScreen == historyScreen {
+		view = m.historyModel.View()
 	} else {
-		return m.downloadModel.View()
+		view = m.downloadModel.View()
 	}
+	if m.footerHeight() != 0 {
+		view = lipgloss.JoinVertical(lipgloss.Left, view, m.footerView())
+	}
+	return view
 }
 
 var program *tea.Program
 
+// version is set at build time with -ldflags "-X main.version=v1.2.3"
+var version string
+
+func appVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
 type ProgramArgs struct {
-	Download    bool `short:"d" long:"download" description:"Start downloading selected items"`
-	APIKey      bool `short:"k" long:"apikey" description:"Ask for API key"`
-	UserId      bool `short:"u" long:"userid" description:"Ask for UserId"`
-	APIEndPoint bool `short:"e" long:"endpoint" description:"Ask for API Endpoint"`
+	Download    bool   `short:"d" long:"download" description:"Start downloading selected items"`
+	APIKey      bool   `short:"k" long:"apikey" description:"Ask for API key"`
+	UserId      bool   `short:"u" long:"userid" description:"Ask for UserId"`
+	APIEndPoint bool   `short:"e" long:"endpoint" description:"Ask for API Endpoint"`
+	Offline     bool   `short:"o" long:"offline" description:"Browse the cached library without contacting the server"`
+	LogFile     string `long:"log-file" description:"Write the log to this file instead of the state directory"`
+	LogLevel    string `long:"log-level" default:"info" choice:"debug" choice:"info" choice:"warn" choice:"error" description:"Minimum level of the logged events"`
+	Export      string `long:"export-history" choice:"csv" choice:"json" description:"Print the download history in this format and exit"`
+	TestWebhook bool   `long:"test-webhooks" description:"Send a test event to the configured webhooks and exit"`
 }
 
 var args ProgramArgs = ProgramArgs{}
@@ -230,13 +396,60 @@ func main() {
 		}
 	}
 
+	logger, err = openLogger(args.LogFile, args.LogLevel)
+	if err != nil {
+		fmt.Println("Error opening the log file:", err)
+		os.Exit(1)
+	}
+	logger.Info("starting", "version", appVersion())
+
+	if args.Export != "" {
+		entries, err := loadHistory()
+		if err == nil {
+			err = writeHistory(os.Stdout, args.Export, entries)
+		}
+		if err != nil {
+			fmt.Println("Error exporting the history:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args.TestWebhook {
+		config := getConfig()
+		if len(config.Webhooks) == 0 {
+			fmt.Println("No webhook configured")
+		}
+		failed := false
+		for i, err := range testWebhooks(config) {
+			host := webhookHost(config.Webhooks[i].URL)
+			if err != nil {
+				failed = true
+				fmt.Println(host+":", err)
+			} else {
+				fmt.Println(host + ": ok")
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
 	m := model{}
 	m.InitModel()
 
-	program = tea.NewProgram(m, tea.WithAltScreen())
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if !m.config.DisableMouse {
+		options = append(options, tea.WithMouseCellMotion())
+	}
+	program = tea.NewProgram(m, options...)
+	m.config.notify = program.Send
 
 	if err := program.Start(); err != nil {
+		logger.Error("error running program", "error", err)
 		fmt.Println("Error running program:", err)
 		program.Kill()
 	}
+	m.config.cache.flush()
 }
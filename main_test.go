@@ -0,0 +1,988 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
+	"jellyfindl/jellyfin/jellyfintest"
+)
+
+// driver runs a model the way a Bubble Tea program does: commands run in
+// their own goroutine and the messages they return are fed back to Update.
+type driver struct {
+	t    *testing.T
+	m    model
+	msgs chan tea.Msg
+	done chan struct{}
+}
+
+// The types of the messages Bubble Tea handles itself, which are unexported.
+var (
+	batchMsgType = reflect.TypeOf(tea.Batch(tea.Quit, tea.Quit)())
+	quitMsgType  = reflect.TypeOf(tea.Quit())
+)
+
+// setup starts a fake server and points a fresh config, cache and download
+// folder at it.
+func setup(t *testing.T, entries []jellyfintest.Entry) (*jellyfintest.Server, string) {
+	server := jellyfintest.NewServer(entries)
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	os.MkdirAll(filepath.Join(dir, "config"), os.ModePerm)
+	args = ProgramArgs{}
+
+	downloads := filepath.Join(dir, "Jellyfin")
+	b, _ := json.Marshal(writedConfig{
+		APIEndpoint:      server.URL,
+		APIKey:           jellyfintest.APIKey,
+		UserId:           jellyfintest.UserId,
+		DownloadLocation: downloads,
+		Retry:            RetryConfig{BaseDelay: "1ms", MaxDelay: "10ms"},
+	})
+	if err := os.WriteFile(getConfigFilePath(), b, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	return server, downloads
+}
+
+// connectedConfig returns the config written by setup with its client and
+// cache, as the model builds them.
+func connectedConfig() *Config {
+	config := getConfig()
+	config.cache = loadCache(config)
+	config.client, config.clientErr = config.newClient()
+	return config
+}
+
+func newDriver(t *testing.T) *driver {
+	m := model{}
+	m.InitModel()
+	d := &driver{
+		t:    t,
+		m:    m,
+		msgs: make(chan tea.Msg, 100),
+		done: make(chan struct{}),
+	}
+	t.Cleanup(func() { close(d.done) })
+	d.m.config.notify = func(msg tea.Msg) {
+		select {
+		case d.msgs <- msg:
+		case <-d.done:
+		}
+	}
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 50})
+	d.run(d.m.Init())
+	return d
+}
+
+func (d *driver) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	go func() {
+		msg := cmd()
+		select {
+		case d.msgs <- msg:
+		case <-d.done:
+		}
+	}()
+}
+
+func (d *driver) send(msg tea.Msg) {
+	switch {
+	case msg == nil:
+	case reflect.TypeOf(msg) == quitMsgType:
+	case reflect.TypeOf(msg) == batchMsgType:
+		cmds := reflect.ValueOf(msg)
+		for i := 0; i < cmds.Len(); i++ {
+			d.run(cmds.Index(i).Interface().(tea.Cmd))
+		}
+	default:
+		next, cmd := d.m.Update(msg)
+		d.m = next.(model)
+		d.run(cmd)
+	}
+}
+
+func (d *driver) key(key string) {
+	switch key {
+	case "enter":
+		d.send(tea.KeyMsg{Type: tea.KeyEnter})
+	case "esc":
+		d.send(tea.KeyMsg{Type: tea.KeyEsc})
+	case "up":
+		d.send(tea.KeyMsg{Type: tea.KeyUp})
+	case "down":
+		d.send(tea.KeyMsg{Type: tea.KeyDown})
+	case "right":
+		d.send(tea.KeyMsg{Type: tea.KeyRight})
+	case "left":
+		d.send(tea.KeyMsg{Type: tea.KeyLeft})
+	default:
+		d.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+}
+
+// waitFor processes messages until cond holds.
+func (d *driver) waitFor(what string, cond func(m model) bool) {
+	d.t.Helper()
+	timeout := time.After(10 * time.Second)
+	for !cond(d.m) {
+		select {
+		case msg := <-d.msgs:
+			d.send(msg)
+		case <-timeout:
+			d.t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// settle processes the messages that arrive for a while.
+func (d *driver) settle() {
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case msg := <-d.msgs:
+			d.send(msg)
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func columnIds(m model, column int) []string {
+	if column >= len(m.jellyfinViewModel.lists) {
+		return nil
+	}
+	var ids []string
+	for _, it := range m.jellyfinViewModel.lists[column].Items() {
+		ids = append(ids, it.(item).id)
+	}
+	return ids
+}
+
+func columnLoaded(column int, ids ...string) func(m model) bool {
+	return func(m model) bool {
+		return reflect.DeepEqual(columnIds(m, column), ids)
+	}
+}
+
+func downloadItemById(m model, id string) (downloadItem, bool) {
+	for _, it := range m.downloadModel.list.Items() {
+		if it := it.(downloadItem); it.id == id {
+			return it, true
+		}
+	}
+	return downloadItem{}, false
+}
+
+func TestBrowse(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+
+	d.waitFor("the root column", columnLoaded(0, "movies", "shows", playlistsId))
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+
+	d.key("down")
+	d.waitFor("the nested folders", func(m model) bool {
+		return reflect.DeepEqual(columnIds(m, 1), []string{"series1"}) &&
+			reflect.DeepEqual(columnIds(m, 2), []string{"season1"}) &&
+			reflect.DeepEqual(columnIds(m, 3), []string{"episode1", "episode2"})
+	})
+	if title := d.m.jellyfinViewModel.lists[3].Title; !strings.HasPrefix(title, "Season 1") {
+		t.Errorf("episodes column title = %q", title)
+	}
+}
+
+func TestSelection(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+	for _, id := range []string{"movies", "movie1", "movie2"} {
+		if !d.m.config.Selected.Contains(id) {
+			t.Errorf("%s should be selected", id)
+		}
+	}
+	d.waitFor("the selection summary", func(m model) bool {
+		return m.bottombarModel.summary.count == 2
+	})
+	if selected := getConfig().Selected; selected.Size() != 3 {
+		t.Errorf("the saved config has %d selected items, want 3", selected.Size())
+	}
+
+	d.key("enter")
+	d.waitFor("the folder deselection", func(m model) bool {
+		return m.config.Selected.IsEmpty()
+	})
+
+	d.key("G")
+	d.key("enter")
+	d.waitFor("the rejected selection", func(m model) bool {
+		return strings.Contains(m.bottombarModel.info, "cannot be selected")
+	})
+	if d.m.config.Selected.Contains(playlistsId) {
+		t.Error("the playlists folder should not be selectable")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server, downloads := setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the downloads", func(m model) bool {
+		return len(m.config.Downloaded) == 2
+	})
+
+	for _, id := range []string{"movie1", "movie2"} {
+		entry, _ := server.Entry(id)
+		file := d.m.config.Downloaded[id]
+		if want := filepath.Join(downloads, "Film", entry.FileName); file != want {
+			t.Errorf("%s downloaded to %s, want %s", id, file, want)
+		}
+		if b, err := os.ReadFile(file); err != nil || string(b) != string(entry.Content) {
+			t.Errorf("%s: got %q, %v", id, b, err)
+		}
+	}
+
+	history, err := loadHistory()
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history: got %v, %v", history, err)
+	}
+	for _, entry := range history {
+		if entry.Outcome != outcomeCompleted || entry.Attempts != 1 || entry.Path != d.m.config.Downloaded[entry.Id] {
+			t.Errorf("history entry %+v", entry)
+		}
+	}
+	var csv strings.Builder
+	if err := writeHistory(&csv, "csv", history); err != nil || strings.Count(csv.String(), "\n") != 3 {
+		t.Errorf("csv export: got %q, %v", csv.String(), err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test are shell commands")
+	}
+	setup(t, jellyfintest.Library())
+	out := t.TempDir()
+	d := newDriver(t)
+	d.m.config.Hooks = HooksConfig{
+		Completed: "cat > " + out + "/$JELLYFINDL_ID.json && echo done $JELLYFINDL_NAME",
+		Drained:   "echo drained $JELLYFINDL_COMPLETED",
+	}
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the drained hook", func(m model) bool {
+		return strings.Contains(m.downloadModel.info, "drained 2")
+	})
+	d.waitFor("the completed hooks", func(m model) bool {
+		it, ok := downloadItemById(m, "movie2")
+		return ok && strings.Contains(it.Description(), "Hook completed: exit 0 · done Sintel")
+	})
+
+	b, err := os.ReadFile(filepath.Join(out, "movie2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload hookPayload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != hookCompleted || payload.Name != "Sintel" || payload.File != d.m.config.Downloaded["movie2"] || payload.Size == 0 {
+		t.Errorf("payload %+v", payload)
+	}
+}
+
+func TestHooksLastFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test are shell commands")
+	}
+	server, _ := setup(t, jellyfintest.Library())
+	server.Fail("/Items/movie1/Download", http.StatusNotFound, 10)
+	d := newDriver(t)
+	d.m.config.Hooks = HooksConfig{Drained: "echo drained $JELLYFINDL_COMPLETED $JELLYFINDL_FAILED"}
+	d.m.config.Queue.Order = []string{"movie2", "movie1"}
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the drained hook", func(m model) bool {
+		return strings.Contains(m.downloadModel.info, "drained 1 1")
+	})
+	if it, _ := downloadItemById(d.m, "movie1"); it.fail == "" {
+		t.Error("the last item should have failed")
+	}
+}
+
+func TestHooksPausedItem(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test are shell commands")
+	}
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	d.m.config.Hooks = HooksConfig{Drained: "echo drained $JELLYFINDL_COMPLETED"}
+	d.m.config.Queue.Order = []string{"movie2", "movie1"}
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.m.downloadModel.queuePaused = true
+	d.waitFor("the queue", func(m model) bool {
+		return len(m.downloadModel.list.Items()) == 2
+	})
+	d.key("j")
+	d.key("p")
+	if it, _ := downloadItemById(d.m, "movie1"); !it.paused {
+		t.Fatal("movie1 should be paused")
+	}
+	d.key("P")
+	d.waitFor("the download", func(m model) bool {
+		return m.config.Downloaded["movie2"] != ""
+	})
+	d.settle()
+	if strings.Contains(d.m.downloadModel.info, "drained") {
+		t.Errorf("the paused item should hold the drained hook back, info %q", d.m.downloadModel.info)
+	}
+
+	for i, v := range d.m.downloadModel.list.Items() {
+		if v.(downloadItem).id == "movie1" {
+			d.m.downloadModel.list.Select(i)
+		}
+	}
+	d.key("p")
+	d.waitFor("the drained hook", func(m model) bool {
+		return strings.Contains(m.downloadModel.info, "drained 2")
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	var mu sync.Mutex
+	var events []hookPayload
+	var texts []string
+	failed := false
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/slack" {
+			var body struct{ Text string }
+			json.Unmarshal(b, &body)
+			texts = append(texts, body.Text)
+			return
+		}
+		var payload hookPayload
+		json.Unmarshal(b, &payload)
+		events = append(events, payload)
+	}))
+	t.Cleanup(hook.Close)
+
+	d := newDriver(t)
+	d.m.config.Webhooks = []WebhookConfig{
+		{URL: hook.URL + "/json"},
+		{URL: hook.URL + "/slack", Format: "slack", Events: []string{webhookQueueFinished}},
+	}
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the webhooks", func(m model) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 4 && len(texts) == 1
+	})
+
+	got := make(map[string]int)
+	for _, e := range events {
+		got[e.Event]++
+	}
+	if got[webhookQueueStarted] != 1 || got[webhookCompleted] != 2 || got[webhookQueueFinished] != 1 {
+		t.Errorf("events %v", got)
+	}
+	if want := "jellyfindl finished downloading: 2 downloaded, 0 failed"; texts[0] != want {
+		t.Errorf("slack text %q, want %q", texts[0], want)
+	}
+}
+
+func TestSchedulePause(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	tomorrow := strings.ToLower(time.Now().AddDate(0, 0, 1).Format("Mon"))[:3]
+	d.m.config.Schedule = ScheduleConfig{Windows: []ScheduleWindow{{Days: []string{tomorrow}, Start: "01:00", End: "02:00"}}}
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the pause", func(m model) bool {
+		return m.downloadModel.paused && strings.Contains(m.View(), "Paused until ")
+	})
+	if len(d.m.config.Downloaded) != 0 || len(d.m.downloadModel.downloading) != 0 {
+		t.Fatal("nothing should be downloaded outside the schedule")
+	}
+
+	d.m.config.Schedule = ScheduleConfig{}
+	d.waitFor("the downloads", func(m model) bool {
+		return len(m.config.Downloaded) == 2 && !m.downloadModel.paused
+	})
+}
+
+func TestWebhookTLS(t *testing.T) {
+	hook := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(hook.Close)
+	config := &Config{TLS: TLSConfig{InsecureSkipVerify: true}, Retry: RetryConfig{MaxAttempts: 1}}
+	err := sendWebhook(context.Background(), WebhookConfig{URL: hook.URL}, hookPayload{Event: webhookCompleted}, config)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("the webhook certificate should be verified, got %v", err)
+	}
+}
+
+func TestInvalidTLS(t *testing.T) {
+	server, _ := setup(t, jellyfintest.Library())
+	config := getConfig()
+	config.TLS.CAFile = filepath.Join(t.TempDir(), "missing.pem")
+	writeConfig(*config)
+
+	d := newDriver(t)
+	d.waitFor("the transport error", func(m model) bool {
+		return strings.Contains(m.bottombarModel.info, "invalid connection settings")
+	})
+	if requests := len(server.Requests()); requests != 0 {
+		t.Errorf("%d requests sent with the default TLS settings", requests)
+	}
+}
+
+func TestCertificateError(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(untrusted.Close)
+	config := getConfig()
+	config.APIEndpoint = untrusted.URL
+	config.Retry.MaxAttempts = 1
+	writeConfig(*config)
+
+	d := newDriver(t)
+	d.waitFor("the certificate error", func(m model) bool {
+		return strings.Contains(m.bottombarModel.info, "certificate error")
+	})
+	if d.m.bottombarModel.input.isActive {
+		t.Error("a certificate error should not ask for another endpoint")
+	}
+}
+
+func TestRules(t *testing.T) {
+	entries := jellyfintest.Library()
+	for i := range entries {
+		if entries[i].Id == "episode1" {
+			entries[i].UserData.Played = true
+		}
+	}
+	setup(t, entries)
+	config := getConfig()
+	config.Selected.Add("episode1")
+	config.NextUnwatched["series1"] = 1
+	writeConfig(*config)
+
+	d := newDriver(t)
+	d.waitFor("the rules", func(m model) bool {
+		return m.config.Selected.Contains("episode2")
+	})
+	if d.m.config.Selected.Contains("episode1") {
+		t.Error("the watched episode should leave the selection")
+	}
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	if saved := getConfig().Selected; !saved.Contains("episode2") || saved.Contains("episode1") {
+		t.Errorf("the rules should be saved, got %v", saved.Values())
+	}
+
+	d.key("down")
+	d.waitFor("the series column", columnLoaded(1, "series1"))
+	d.key("right")
+	d.key("n")
+	d.waitFor("the rule prompt", func(m model) bool {
+		return m.bottombarModel.input.isActive
+	})
+	if prompt := d.m.bottombarModel.input.textInput.Prompt; prompt != "Next unwatched episodes of Elephants Dream"+promptStyle.Render(" ? ") {
+		t.Errorf("rule prompt = %q", prompt)
+	}
+}
+
+func TestDownloadRetry(t *testing.T) {
+	server, _ := setup(t, jellyfintest.Library())
+	server.Fail("/Items/movie1/Download", http.StatusServiceUnavailable, 1)
+	d := newDriver(t)
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the downloads", func(m model) bool {
+		return len(m.config.Downloaded) == 2
+	})
+	if it, _ := downloadItemById(d.m, "movie1"); it.attempts != 2 {
+		t.Errorf("movie1 took %d attempts, want 2", it.attempts)
+	}
+}
+
+func TestDownloadFailure(t *testing.T) {
+	server, downloads := setup(t, jellyfintest.Library())
+	server.Fail("/Items/movie1/Download", http.StatusNotFound, 10)
+	d := newDriver(t)
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("right")
+	d.key("enter")
+	d.waitFor("the movie selection", func(m model) bool {
+		return m.config.Selected.Contains("movie1")
+	})
+
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the failure", func(m model) bool {
+		it, ok := downloadItemById(m, "movie1")
+		return ok && it.fail != ""
+	})
+	if _, ok := d.m.config.Downloaded["movie1"]; ok {
+		t.Error("a failed download should not be marked as downloaded")
+	}
+	if _, err := os.Stat(filepath.Join(downloads, "Film", "bunny.mkv")); !os.IsNotExist(err) {
+		t.Errorf("the partial file should be removed, got %v", err)
+	}
+	if it, _ := downloadItemById(d.m, "movie1"); !strings.Contains(it.Description(), "not found") {
+		t.Errorf("description = %q", it.Description())
+	}
+}
+
+func TestRemove(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("right")
+	d.key("enter")
+	d.waitFor("the movie selection", func(m model) bool {
+		return m.config.Selected.Contains("movie1")
+	})
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the download", func(m model) bool {
+		_, ok := m.config.Downloaded["movie1"]
+		return ok
+	})
+	file := d.m.config.Downloaded["movie1"]
+
+	d.key("esc")
+	d.waitFor("the main screen", func(m model) bool {
+		return m.currentScreen == mainScreen && len(columnIds(m, 1)) == 2
+	})
+	d.key("r")
+	if _, ok := d.m.config.Downloaded["movie1"]; ok {
+		t.Error("movie1 should not be marked as downloaded anymore")
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("%s should be removed, got %v", file, err)
+	}
+	if _, ok := getConfig().Downloaded["movie1"]; ok {
+		t.Error("the removal should be saved")
+	}
+}
+
+func TestScheduleResume(t *testing.T) {
+	content := []byte(strings.Repeat("jellyfin", 12500))
+	setup(t, []jellyfintest.Entry{
+		{Item: jellyfin.Item{Id: "movies", Name: "Movies", IsFolder: true}},
+		{Item: jellyfin.Item{Id: "big1", Name: "Big 1", Type: "Movie"}, ParentId: "movies", FileName: "big1.mkv", Content: content},
+		{Item: jellyfin.Item{Id: "big2", Name: "Big 2", Type: "Movie"}, ParentId: "movies", FileName: "big2.mkv", Content: content},
+	})
+	d := newDriver(t)
+	d.m.config.Schedule = ScheduleConfig{Windows: []ScheduleWindow{{RateLimit: "20KB"}}}
+	d.waitFor("the movies column", columnLoaded(1, "big1", "big2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("big2")
+	})
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the transfer", func(m model) bool {
+		return len(m.downloadModel.downloading) != 0
+	})
+
+	tomorrow := strings.ToLower(time.Now().AddDate(0, 0, 1).Format("Mon"))[:3]
+	d.m.config.Schedule = ScheduleConfig{Windows: []ScheduleWindow{{Days: []string{tomorrow}, Start: "01:00", End: "02:00", RateLimit: "20KB"}}}
+	d.waitFor("the pause", func(m model) bool {
+		return m.downloadModel.paused && len(m.downloadModel.downloading) == 0
+	})
+	// Let the other item start first when the window opens
+	for i, v := range d.m.downloadModel.list.Items() {
+		if v.(downloadItem).pausedUntil.IsZero() {
+			d.m.downloadModel.list.Select(i)
+		}
+	}
+	d.key("+")
+
+	d.m.config.Schedule = ScheduleConfig{Windows: []ScheduleWindow{{RateLimit: "20KB"}}}
+	d.waitFor("the window", func(m model) bool {
+		return !m.downloadModel.paused && len(m.downloadModel.downloading) != 0
+	})
+	for _, v := range d.m.downloadModel.list.Items() {
+		if it := v.(downloadItem); strings.Contains(it.Description(), "Paused until") {
+			t.Errorf("%s: description = %q", it.id, it.Description())
+		}
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	// Hold the queue back while it is reordered
+	tomorrow := strings.ToLower(time.Now().AddDate(0, 0, 1).Format("Mon"))[:3]
+	d.m.config.Schedule = ScheduleConfig{Windows: []ScheduleWindow{{Days: []string{tomorrow}, Start: "01:00", End: "02:00"}}}
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the queue", func(m model) bool {
+		return m.downloadModel.paused && len(m.downloadModel.list.Items()) == 2
+	})
+	queue := func(m model) string {
+		next, _ := m.downloadModel.getNext()
+		return next
+	}
+	if next := queue(d.m); next != "movie1" {
+		t.Fatalf("the queue starts with %s", next)
+	}
+
+	d.key("j")
+	d.key("K")
+	if next := queue(d.m); next != "movie2" || strings.Join(d.m.config.Queue.Order, ",") != "movie2,movie1" {
+		t.Errorf("after moving movie2 up, next is %s and the order %v", next, d.m.config.Queue.Order)
+	}
+	d.key("j")
+	d.key("+")
+	if next := queue(d.m); next != "movie1" || d.m.config.Queue.Priority["movie1"] != 1 {
+		t.Errorf("after raising movie1, next is %s", next)
+	}
+	d.key("j")
+	d.key("t")
+	if next := queue(d.m); next != "movie2" || !getConfig().Queue.isPinned("movie2") {
+		t.Errorf("after pinning movie2, next is %s", next)
+	}
+
+	d.m.config.Schedule = ScheduleConfig{}
+	d.waitFor("the downloads", func(m model) bool {
+		return len(m.config.Downloaded) == 2
+	})
+	if q := d.m.config.Queue; len(q.Order) != 0 || len(q.Pinned) != 0 || len(q.Priority) != 0 {
+		t.Errorf("the downloaded items should leave the queue, got %+v", q)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	setup(t, []jellyfintest.Entry{
+		{Item: jellyfin.Item{Id: "movies", Name: "Movies", IsFolder: true}},
+		{Item: jellyfin.Item{Id: "big", Name: "Big", Type: "Movie"}, ParentId: "movies", FileName: "big.mkv", Content: []byte(strings.Repeat("jellyfin", 12500))},
+	})
+	d := newDriver(t)
+	d.m.config.Schedule = ScheduleConfig{Windows: []ScheduleWindow{{RateLimit: "20KB"}}}
+	d.waitFor("the movies column", columnLoaded(1, "big"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("big")
+	})
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the transfer", func(m model) bool {
+		it, ok := downloadItemById(m, "big")
+		return ok && it.resp != nil && it.resp.BytesComplete() > 0
+	})
+
+	d.key("enter")
+	file := filepath.Join(d.m.config.DownloadLocation, "Film", "big.mkv")
+	d.waitFor("the removal of the partial file", func(m model) bool {
+		_, err := os.Stat(file)
+		return os.IsNotExist(err)
+	})
+	if it, _ := downloadItemById(d.m, "big"); it.downloadStarted {
+		t.Error("the download should be cancelled")
+	}
+}
+
+func TestPause(t *testing.T) {
+	content := []byte(strings.Repeat("jellyfin", 12500))
+	setup(t, []jellyfintest.Entry{
+		{Item: jellyfin.Item{Id: "movies", Name: "Movies", IsFolder: true}},
+		{Item: jellyfin.Item{Id: "big", Name: "Big", Type: "Movie"}, ParentId: "movies", FileName: "big.mkv", Content: content},
+	})
+	d := newDriver(t)
+	// Slow the download down to pause it midway
+	d.m.config.Schedule = ScheduleConfig{Windows: []ScheduleWindow{{RateLimit: "20KB"}}}
+	d.waitFor("the movies column", columnLoaded(1, "big"))
+	d.key("enter")
+	d.waitFor("the folder selection", func(m model) bool {
+		return m.config.Selected.Contains("big")
+	})
+	d.send(buttonPressedMsg(DownloadAll))
+	d.waitFor("the transfer", func(m model) bool {
+		it, ok := downloadItemById(m, "big")
+		return ok && it.resp != nil && it.resp.BytesComplete() > 0
+	})
+
+	d.key("p")
+	d.waitFor("the pause", func(m model) bool {
+		it, _ := downloadItemById(m, "big")
+		return it.paused && !it.downloadStarted && len(m.downloadModel.downloading) == 0
+	})
+	it, _ := downloadItemById(d.m, "big")
+	if !strings.Contains(it.Description(), "Paused") || !strings.Contains(it.Description(), "kept") {
+		t.Errorf("description = %q", it.Description())
+	}
+	file := filepath.Join(d.m.config.DownloadLocation, "Film", "big.mkv")
+	if info, err := os.Stat(file); err != nil || info.Size() == 0 || info.Size() >= int64(len(content)) {
+		t.Fatalf("the partial file should be kept, got %v, %v", info, err)
+	}
+
+	// Resuming the item waits for the queue
+	d.key("P")
+	d.key("p")
+	if it, _ := downloadItemById(d.m, "big"); it.paused || it.downloadStarted || !strings.Contains(d.m.View(), "Queue paused") {
+		t.Fatal("the paused queue should hold the item back")
+	}
+
+	d.m.config.Schedule = ScheduleConfig{}
+	d.key("P")
+	d.waitFor("the download", func(m model) bool {
+		return m.config.Downloaded["big"] != ""
+	})
+	if it, _ := downloadItemById(d.m, "big"); !it.resp.DidResume {
+		t.Error("the download should resume from the partial file")
+	}
+	if b, err := os.ReadFile(file); err != nil || string(b) != string(content) {
+		t.Errorf("got %d bytes, %v", len(b), err)
+	}
+}
+
+// library returns a folder holding n movies.
+func library(n int) []jellyfintest.Entry {
+	entries := []jellyfintest.Entry{{Item: jellyfin.Item{Id: "movies", Name: "Movies", IsFolder: true}}}
+	for i := 0; i < n; i++ {
+		entries = append(entries, jellyfintest.Entry{
+			Item:     jellyfin.Item{Id: fmt.Sprint("movie", i), Name: fmt.Sprint("Movie ", i), Type: "Movie"},
+			ParentId: "movies",
+		})
+	}
+	return entries
+}
+
+func TestStaleCache(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	stale := loadCache(getConfig())
+	stale.Entries[""] = cacheEntry{
+		Items:   []jellyfin.Item{{Id: "movies", Name: "Movies", IsFolder: true}},
+		Total:   1,
+		Fetched: time.Now().Add(-2 * defaultCacheTTL),
+	}
+	stale.save()
+
+	d := newDriver(t)
+	d.waitFor("the cached root column", columnLoaded(0, "movies"))
+	d.waitFor("the revalidated root column", columnLoaded(0, "movies", "shows", playlistsId))
+
+	d.m.config.cache.flush()
+	if entry, ok, fresh := loadCache(getConfig()).get(""); !ok || !fresh || entry.Total != 3 {
+		t.Errorf("the revalidated page should be saved, got %d items, fresh %t", entry.Total, fresh)
+	}
+}
+
+func TestRevalidateKeepsPages(t *testing.T) {
+	entries := library(150)
+	setup(t, entries)
+	stale := loadCache(getConfig())
+	var items []jellyfin.Item
+	for _, e := range entries[1:] {
+		items = append(items, e.Item)
+	}
+	stale.Entries["movies"] = cacheEntry{Items: items, Total: 150, Fetched: time.Now().Add(-2 * defaultCacheTTL)}
+	stale.save()
+
+	d := newDriver(t)
+	d.waitFor("the revalidation", func(m model) bool {
+		_, _, fresh := m.config.cache.get("movies")
+		return fresh
+	})
+	if entry, _, _ := d.m.config.cache.get("movies"); len(entry.Items) != 150 {
+		t.Errorf("the revalidation kept %d cached items, want 150", len(entry.Items))
+	}
+
+	// The server went away during a revalidation
+	root, _, _ := d.m.config.cache.get("")
+	d.send(revalidatedMsg{"", jellyfin.Response{Items: append(root.Items, downloadedFolder), TotalRecordCount: root.Total + 1}, true})
+	if entry, _, _ := d.m.config.cache.get(""); len(entry.Items) != len(root.Items) {
+		t.Errorf("the offline page should not be cached, got %d items", len(entry.Items))
+	}
+}
+
+func TestPageFailure(t *testing.T) {
+	server, _ := setup(t, library(150))
+	d := newDriver(t)
+	d.waitFor("the first page", func(m model) bool { return len(columnIds(m, 1)) == 100 })
+	d.key("right")
+
+	server.Fail("/Users", http.StatusNotFound, 1)
+	d.key("G")
+	d.waitFor("the failed page", func(m model) bool {
+		_, failed := m.jellyfinViewModel.failedPage["movies"]
+		return failed
+	})
+	requests := len(server.Requests())
+	d.settle()
+	if got := len(server.Requests()); got != requests {
+		t.Fatalf("%d requests after the failure, the page should wait for the user", got-requests)
+	}
+
+	d.key("k")
+	d.waitFor("the retried page", func(m model) bool { return len(columnIds(m, 1)) == 150 })
+}
+
+func TestGetChildsPages(t *testing.T) {
+	server, _ := setup(t, library(250))
+	config := connectedConfig()
+
+	res := getChilds(context.Background(), "movies", config)
+	if len(res.Items) != 250 || res.TotalRecordCount != 250 {
+		t.Fatalf("got %d items out of %d, want 250", len(res.Items), res.TotalRecordCount)
+	}
+	if requests := len(server.Requests()); requests != 3 {
+		t.Errorf("%d requests, want 3 pages", requests)
+	}
+}
+
+func TestGetItemsChunks(t *testing.T) {
+	server, _ := setup(t, library(250))
+	config := connectedConfig()
+
+	var ids []string
+	for i := 0; i < 250; i++ {
+		ids = append(ids, fmt.Sprint("movie", i))
+	}
+	if res := getItems(context.Background(), ids, config); len(res.Items) != 250 {
+		t.Fatalf("got %d items, want 250", len(res.Items))
+	}
+	if requests := len(server.Requests()); requests != 2 {
+		t.Errorf("%d requests, want 2 chunks", requests)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+	if footer := d.m.footerView(); !strings.Contains(footer, "select") {
+		t.Errorf("footer = %q", footer)
+	}
+
+	d.key("?")
+	if !d.m.showHelp || !strings.Contains(d.m.View(), "remove download") {
+		t.Fatalf("the overlay should list the browser bindings:\n%s", d.m.View())
+	}
+	d.key("x")
+	if d.m.showHelp {
+		t.Error("any key should close the overlay")
+	}
+
+	d.key("tab")
+	d.key("?")
+	if view := d.m.View(); !strings.Contains(view, "Keys · bottombar") || strings.Contains(view, "remove download") {
+		t.Errorf("the overlay should list the bottom bar bindings:\n%s", view)
+	}
+	d.key("x")
+
+	d.key("H")
+	if d.m.footerHeight() != 0 || !getConfig().HideHelpFooter {
+		t.Error("H should hide the footer")
+	}
+}
+
+func TestMouse(t *testing.T) {
+	setup(t, jellyfintest.Library())
+	d := newDriver(t)
+	d.waitFor("the movies column", columnLoaded(1, "movie1", "movie2"))
+
+	// Find where Sintel is drawn to check the layout computed for the clicks
+	x := d.m.jellyfinViewModel.lists[0].Width() + 8
+	y := -1
+	for i, line := range strings.Split(d.m.View(), "\n") {
+		if strings.Contains(line, "Sintel") {
+			y = i
+		}
+	}
+	click := tea.MouseMsg{X: x, Y: y, Type: tea.MouseLeft}
+
+	d.send(click)
+	if it := d.m.jellyfinViewModel.lists[1].SelectedItem().(item); d.m.jellyfinViewModel.focused != 1 || it.id != "movie2" {
+		t.Fatalf("the click should highlight Sintel, got column %d and %s", d.m.jellyfinViewModel.focused, it.id)
+	}
+	d.send(click)
+	d.waitFor("the double-click selection", func(m model) bool {
+		return m.config.Selected.Contains("movie2")
+	})
+
+	d.send(tea.MouseMsg{X: x, Y: y, Type: tea.MouseWheelUp})
+	if it := d.m.jellyfinViewModel.lists[1].SelectedItem().(item); it.id != "movie1" {
+		t.Errorf("the wheel should move up to movie1, got %s", it.id)
+	}
+
+	d.send(tea.MouseMsg{X: 2, Y: len(strings.Split(d.m.jellyfinViewModel.View(), "\n")), Type: tea.MouseLeft})
+	d.waitFor("the download screen", func(m model) bool {
+		return m.currentScreen == downloadScreen
+	})
+}
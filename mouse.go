@@ -0,0 +1,180 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const doubleClickDelay = 400 * time.Millisecond
+
+type click struct {
+	x, y int
+	at   time.Time
+}
+
+// isDoubleClick tells if msg clicks the same cell as the previous click,
+// shortly after it.
+func (m *model) isDoubleClick(msg tea.MouseMsg) bool {
+	now := time.Now()
+	double := m.lastClick.x == msg.X && m.lastClick.y == msg.Y && now.Sub(m.lastClick.at) < doubleClickDelay
+	m.lastClick = click{msg.X, msg.Y, now}
+	if double {
+		m.lastClick = click{}
+	}
+	return double
+}
+
+func (m model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if msg.Type != tea.MouseLeft && msg.Type != tea.MouseWheelUp && msg.Type != tea.MouseWheelDown {
+		return m, nil
+	}
+	if m.showHelp {
+		if msg.Type == tea.MouseLeft {
+			m.showHelp = false
+		}
+		return m, nil
+	}
+	var double bool
+	if msg.Type == tea.MouseLeft {
+		double = m.isDoubleClick(msg)
+	}
+
+	switch m.currentScreen {
+	case mainScreen:
+		if m.bottombarModel.input.isActive {
+			return m, nil
+		}
+		if browserHeight := lipgloss.Height(m.jellyfinViewModel.View()); msg.Y < browserHeight {
+			m.focus = browser
+			m.jellyfinViewModel.isActive = true
+			m.bottombarModel.isActive = false
+			return m.callJellyfinUpdate(mouseMsg{msg, double})
+		} else if msg.Y == browserHeight && msg.Type == tea.MouseLeft {
+			button, ok := m.bottombarModel.buttonAt(msg.X)
+			if !ok {
+				return m, nil
+			}
+			m.focus = bottombar
+			m.jellyfinViewModel.isActive = false
+			m.bottombarModel.isActive = true
+			m.bottombarModel.focused = button
+			return m, m.bottombarModel.buttons[button].sendPressed
+		}
+	case downloadScreen:
+		return m.callDownloadUpdate(mouseMsg{msg, double})
+	}
+	return m, nil
+}
+
+// mouseMsg is a mouse event forwarded to a child model.
+type mouseMsg struct {
+	tea.MouseMsg
+	double bool
+}
+
+// listItemAt returns the index of the visible item rendered on the line y of
+// the list view.
+func listItemAt(l list.Model, delegate list.DefaultDelegate, y int) (int, bool) {
+	if l.ShowTitle() || (l.ShowFilter() && l.FilteringEnabled()) {
+		if l.ShowTitle() || l.FilterState() == list.Filtering {
+			y -= 1 + l.Styles.TitleBar.GetVerticalFrameSize()
+		} else {
+			y--
+		}
+	}
+	if l.ShowStatusBar() {
+		y -= 1 + l.Styles.StatusBar.GetVerticalFrameSize()
+	}
+
+	step := delegate.Height() + delegate.Spacing()
+	if y < 0 || y%step >= delegate.Height() {
+		return 0, false
+	}
+	index := l.Paginator.Page*l.Paginator.PerPage + y/step
+	if y/step >= l.Paginator.PerPage || index >= len(l.VisibleItems()) {
+		return 0, false
+	}
+	return index, true
+}
+
+// scroll moves the cursor of a list with the mouse wheel.
+func scroll(l *list.Model, msg tea.MouseMsg) bool {
+	switch msg.Type {
+	case tea.MouseWheelUp:
+		l.CursorUp()
+	case tea.MouseWheelDown:
+		l.CursorDown()
+	default:
+		return false
+	}
+	return true
+}
+
+// columnAt returns the column rendered at x, following setListsSize.
+func (m jellyfinViewModel) columnAt(x int) (int, bool) {
+	h, _ := docStyle.GetFrameSize()
+	var start int
+	for i, l := range m.lists {
+		start += l.Width() + h
+		if x < start {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (m jellyfinViewModel) updateMouse(msg mouseMsg) (jellyfinViewModel, tea.Cmd) {
+	column, ok := m.columnAt(msg.X)
+	if !ok || m.isFiltering() {
+		return m, nil
+	}
+	m.focused = column
+	l := m.lists[column]
+	if !scroll(l, msg.MouseMsg) {
+		top := docStyle.GetMarginTop() + docStyle.GetBorderTopWidth()
+		index, ok := listItemAt(*l, listDelegate(false), msg.Y-top)
+		if !ok {
+			return m, m.detailsCmd()
+		}
+		l.Select(index)
+		if msg.double {
+			return m, tea.Batch(m.refresh(), m.selectCmd())
+		}
+	}
+	m.retryPage()
+	return m, tea.Batch(m.refresh(), m.loadMoreCmd())
+}
+
+func (m downloadModel) updateMouse(msg mouseMsg) (downloadModel, tea.Cmd) {
+	if m.list.SettingFilter() || scroll(&m.list, msg.MouseMsg) {
+		return m, nil
+	}
+	// The summary line is above the list
+	index, ok := listItemAt(m.list, listDelegate(true), msg.Y-1)
+	if !ok {
+		return m, nil
+	}
+	m.list.Select(index)
+	if msg.double {
+		return m.toggle(msg)
+	}
+	return m, nil
+}
+
+// buttonAt returns the button rendered at x.
+func (m bottombarModel) buttonAt(x int) (int, bool) {
+	if !m.buttonsActive {
+		return 0, false
+	}
+	var start int
+	for i, button := range m.buttons {
+		start += lipgloss.Width(button.View())
+		if x < start {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// itemPausedMsg pauses or resumes a single download.
+type itemPausedMsg struct {
+	paused bool
+}
+
+// queuePausedMsg marks the downloads stopped by the pause of the queue.
+type queuePausedMsg struct{}
+
+// busy tells if a download is running or about to.
+func (m downloadModel) busy() bool {
+	for _, v := range m.list.Items() {
+		if item := v.(downloadItem); item.downloadStarted && !item.downloadCompleted {
+			return true
+		}
+	}
+	return false
+}
+
+// stop interrupts a running download without cancelling it: its partial file
+// is kept and the next start resumes it with a range request.
+func (m downloadModel) stop(id string, msg tea.Msg) (downloadModel, tea.Cmd) {
+	resp := m.downloading[id]
+	delete(m.downloading, id)
+	item := m.getItem(id)
+	if resp != nil {
+		m.kept[resp] = true
+		item.partial = resp.BytesComplete()
+		item.size = resp.Size()
+	}
+	item.downloadStarted = false
+	item.resp = nil
+	m, cmd := m.updateItem(item, msg)
+	return m, tea.Batch(cmd, func() tea.Msg {
+		if resp != nil {
+			resp.Cancel()
+		}
+		return nil
+	})
+}
+
+// pauseItem pauses the highlighted download, freeing its slot for the next
+// one, or resumes it.
+func (m downloadModel) pauseItem() (downloadModel, tea.Cmd) {
+	item := m.list.SelectedItem().(downloadItem)
+	switch {
+	case item.downloadCompleted:
+		return m, nil
+	case item.paused:
+		logger.Info("download resumed", "id", item.id)
+		m, cmd := m.updateItem(item, itemPausedMsg{false})
+		if m.busy() {
+			return m, cmd
+		}
+		return m, tea.Batch(cmd, m.downloadItem(item.id, getDownloadLocation(item.jellyfinItem)))
+	case item.downloadStarted:
+		logger.Info("download paused", "id", item.id)
+		m, cmd := m.stop(item.id, itemPausedMsg{true})
+		if m.busy() {
+			return m, cmd
+		}
+		return m, tea.Batch(cmd, m.downloadItem(m.getNext()))
+	}
+	return m.updateItem(item, itemPausedMsg{true})
+}
+
+// pauseQueue stops every download until the queue is resumed, keeping their
+// partial files.
+func (m downloadModel) pauseQueue() (downloadModel, tea.Cmd) {
+	m.queuePaused = !m.queuePaused
+	if !m.queuePaused {
+		logger.Info("queue resumed")
+		if m.busy() {
+			return m, nil
+		}
+		return m, m.downloadItem(m.getNext())
+	}
+
+	logger.Info("queue paused")
+	var running []string
+	for _, v := range m.list.Items() {
+		if item := v.(downloadItem); item.downloadStarted {
+			running = append(running, item.id)
+		}
+	}
+	var cmds []tea.Cmd
+	for _, id := range running {
+		var cmd tea.Cmd
+		m, cmd = m.stop(id, queuePausedMsg{})
+		cmds = append(cmds, cmd)
+	}
+	return m, tea.Batch(cmds...)
+}
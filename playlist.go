@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
+)
+
+const playlistsId = "jellyfindl-playlists"
+
+var playlistsFolder = jellyfin.Item{Name: "Playlists & Collections", Id: playlistsId, IsFolder: true}
+
+var fileNameReplacer = strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "-", "?", "", "\"", "", "<", "", ">", "", "|", "-")
+
+// writePlaylistFiles writes an .m3u8 for every selected playlist, in the
+// playlist order, referencing the members that have been downloaded.
+func writePlaylistFiles(items map[string]jellyfin.Item, config *Config) tea.Cmd {
+	return func() tea.Msg {
+		root := getDownloadRoot(config)
+		var written int
+		for _, playlist := range items {
+			if playlist.Type != "Playlist" || !config.Selected.Contains(playlist.Id) {
+				continue
+			}
+
+			content := "#EXTM3U\n"
+			for _, member := range getChilds(context.Background(), playlist.Id, config).Items {
+				file, ok := config.Downloaded[member.Id]
+				if !ok {
+					continue
+				}
+				if rel, err := filepath.Rel(root, file); err == nil {
+					file = filepath.ToSlash(rel)
+				}
+				content += fmt.Sprintf("#EXTINF:%d,%s\n%s\n", member.RunTimeTicks/10000000, member.Name, file)
+			}
+
+			dest := filepath.Join(root, fileNameReplacer.Replace(playlist.Name)+".m3u8")
+			if err := os.WriteFile(dest, []byte(content), 0644); err != nil {
+				return infoMsg{"Could not write " + dest + ": " + err.Error()}
+			}
+			written++
+		}
+		return infoMsg{fmt.Sprintf("Wrote %d playlist files", written)}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const maxPriority = 3
+
+// QueueConfig is the order chosen in the download screen. Pinned items come
+// first, then the higher priorities, then the Order the items were moved in.
+// The items left out keep the default order after them.
+type QueueConfig struct {
+	Order    []string
+	Pinned   []string
+	Priority map[string]int
+}
+
+func (q QueueConfig) isPinned(id string) bool {
+	for _, v := range q.Pinned {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (q QueueConfig) rank(id string) int {
+	for i, v := range q.Order {
+		if v == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// forget removes an item from the queue, once it is downloaded.
+func (q *QueueConfig) forget(id string) {
+	q.Order = without(q.Order, id)
+	q.Pinned = without(q.Pinned, id)
+	delete(q.Priority, id)
+}
+
+func without(ids []string, id string) []string {
+	kept := ids[:0]
+	for _, v := range ids {
+		if v != id {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// queued sets the place of the item in the queue from the config.
+func (i downloadItem) queued(q QueueConfig) downloadItem {
+	i.pinned = q.isPinned(i.id)
+	i.priority = q.Priority[i.id]
+	i.rank = q.rank(i.id)
+	return i
+}
+
+// queueLess orders two items by their place in the queue. The second result
+// is false when they share the same place.
+func queueLess(item1, item2 downloadItem) (bool, bool) {
+	switch {
+	case item1.pinned != item2.pinned:
+		return item1.pinned, true
+	case item1.priority != item2.priority:
+		return item1.priority > item2.priority, true
+	case item1.rank != item2.rank:
+		// The ranked items come before the others
+		if item1.rank == 0 || item2.rank == 0 {
+			return item2.rank == 0, true
+		}
+		return item1.rank < item2.rank, true
+	}
+	return false, false
+}
+
+// queueMarker shows the place of the item in the queue before its title.
+func (i downloadItem) queueMarker() string {
+	var marker string
+	if i.pinned {
+		marker = "📌 "
+	}
+	if i.priority != 0 {
+		marker += highlightStyle.Render(fmt.Sprintf("%+d", i.priority)) + " "
+	}
+	return marker
+}
+
+// saveQueue writes the order of the list in the config, and sorts it again.
+func (m downloadModel) saveQueue(items []list.Item, id string) (downloadModel, tea.Cmd) {
+	q := &m.config.Queue
+	q.Order = q.Order[:0]
+	q.Pinned = q.Pinned[:0]
+	q.Priority = make(map[string]int)
+	var rank int
+	for index, v := range items {
+		item := v.(downloadItem)
+		if item.downloadCompleted {
+			continue
+		}
+		rank++
+		item.rank = rank
+		q.Order = append(q.Order, item.id)
+		if item.pinned {
+			q.Pinned = append(q.Pinned, item.id)
+		}
+		if item.priority != 0 {
+			q.Priority[item.id] = item.priority
+		}
+		items[index] = item
+	}
+	writeConfig(*m.config)
+
+	sortList(items)
+	cmd := m.list.SetItems(items)
+	for index, v := range items {
+		if v.(downloadItem).id == id {
+			m.list.Select(index)
+		}
+	}
+	return m, cmd
+}
+
+// reorder applies a change of the queue to the highlighted item.
+func (m downloadModel) reorder(change func(items []list.Item, index int) bool) (downloadModel, tea.Cmd) {
+	if m.list.FilterState() != list.Unfiltered {
+		m.info = "Clear the filter to change the order of the queue"
+		return m, nil
+	}
+	items := m.list.Items()
+	index := m.list.Index()
+	if index >= len(items) || items[index].(downloadItem).downloadCompleted {
+		return m, nil
+	}
+	id := items[index].(downloadItem).id
+	if !change(items, index) {
+		return m, nil
+	}
+	return m.saveQueue(items, id)
+}
+
+// move swaps the highlighted item with the previous or the next one, when
+// they share the same pin and priority.
+func (m downloadModel) move(down bool) (downloadModel, tea.Cmd) {
+	return m.reorder(func(items []list.Item, index int) bool {
+		other := index - 1
+		if down {
+			other = index + 1
+		}
+		if other < 0 || other >= len(items) {
+			return false
+		}
+		item, next := items[index].(downloadItem), items[other].(downloadItem)
+		if next.downloadCompleted || next.pinned != item.pinned || next.priority != item.priority {
+			return false
+		}
+		items[index], items[other] = items[other], items[index]
+		return true
+	})
+}
+
+func (m downloadModel) togglePin() (downloadModel, tea.Cmd) {
+	return m.reorder(func(items []list.Item, index int) bool {
+		item := items[index].(downloadItem)
+		item.pinned = !item.pinned
+		items[index] = item
+		return true
+	})
+}
+
+func (m downloadModel) changePriority(delta int) (downloadModel, tea.Cmd) {
+	return m.reorder(func(items []list.Item, index int) bool {
+		item := items[index].(downloadItem)
+		priority := max(-maxPriority, min(maxPriority, item.priority+delta))
+		if priority == item.priority {
+			return false
+		}
+		item.priority = priority
+		items[index] = item
+		return true
+	})
+}
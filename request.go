@@ -1,150 +1,315 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
-	"os"
-	"strings"
+	"time"
 
 	"github.com/cavaliergopher/grab/v3"
-	"github.com/charmbracelet/lipgloss"
-	"github.com/google/go-querystring/query"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
 )
 
-type JellyfinItem struct {
-	Name,
-	Id,
-	SeriesName,
-	SeasonName string
-	SeasonNumber  int `json:"ParentIndexNumber"`
-	EpisodeNumber int `json:"IndexNumber"`
-	IsFolder      bool
+// errInvalidTransport is returned for every request while the TLS or proxy
+// settings cannot be loaded, rather than silently ignoring them.
+var errInvalidTransport = errors.New("invalid connection settings")
+
+// newClient builds the API client for the current credentials. It is built
+// when the model is initialised and again in Update when they change, the
+// commands only read it.
+func (c *Config) newClient() (*jellyfin.Client, error) {
+	logger.addSecret(c.APIKey)
+	logger.addSecret(c.BasicAuth.Password)
+	for _, value := range c.Headers {
+		logger.addSecret(value)
+	}
+
+	httpClient, err := jellyfin.NewHTTPClient(c.transportOptions())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidTransport, err)
+	}
+	client := jellyfin.NewClient(c.APIEndpoint, c.APIKey, c.UserId)
+	client.HTTPClient = httpClient
+	client.ClientName = "jellyfindl"
+	client.Device = c.deviceName()
+	client.DeviceId = c.DeviceId
+	client.Version = appVersion()
+	client.Retry = c.retryPolicy()
+	client.RateLimiter = bandwidth
+	client.Headers = make(http.Header)
+	for key, value := range c.Headers {
+		client.Headers.Set(key, value)
+	}
+	if c.BasicAuth.Username != "" {
+		client.BasicAuth = &jellyfin.BasicAuth{Username: c.BasicAuth.Username, Password: c.BasicAuth.Password}
+	}
+	client.OnRequest = func(req *http.Request) {
+		c.send(infoMsg{"Fetching " + req.URL.String()})
+	}
+	client.OnDone = func(req *http.Request, resp *http.Response, latency time.Duration, err error) {
+		var status int
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		if err != nil {
+			logger.Warn("request failed", "method", req.Method, "url", req.URL, "status", status, "latency", latency, "error", err)
+		} else {
+			logger.Info("request", "method", req.Method, "url", req.URL, "status", status, "latency", latency)
+		}
+		c.send(infoMsg{""})
+	}
+	return client, nil
+}
+
+// jellyfinClient returns the API client, or the error of its settings.
+func (c *Config) jellyfinClient() (*jellyfin.Client, error) {
+	return c.client, c.clientErr
+}
+
+// send reports a message to the running program, if any.
+func (c *Config) send(msg tea.Msg) {
+	if c.notify != nil {
+		c.notify(msg)
+	}
+}
+
+func (c *Config) deviceName() string {
+	if c.DeviceName != "" {
+		return c.DeviceName
+	}
+	return jellyfin.Hostname()
+}
+
+// retryPolicy returns the configured retry policy, unset values falling back
+// to the defaults.
+func (c *Config) retryPolicy() jellyfin.RetryPolicy {
+	policy := jellyfin.DefaultRetryPolicy()
+	if c.Retry.MaxAttempts != 0 {
+		policy.MaxAttempts = c.Retry.MaxAttempts
+	}
+	if delay, err := time.ParseDuration(c.Retry.BaseDelay); err == nil {
+		policy.BaseDelay = delay
+	}
+	if delay, err := time.ParseDuration(c.Retry.MaxDelay); err == nil {
+		policy.MaxDelay = delay
+	}
+	if c.Retry.RetryableStatus != nil {
+		policy.RetryableStatus = c.Retry.RetryableStatus
+	}
+	if c.Retry.RetryNetworkErrors != nil {
+		policy.RetryNetworkErrors = *c.Retry.RetryNetworkErrors
+	}
+	return policy
+}
+
+// transportOptions returns the configured timeouts, unset values falling back
+// to the defaults.
+func (c *Config) transportOptions() jellyfin.TransportOptions {
+	opts := jellyfin.DefaultTransportOptions()
+	if timeout, err := time.ParseDuration(c.Timeouts.Connect); err == nil {
+		opts.ConnectTimeout = timeout
+	}
+	if timeout, err := time.ParseDuration(c.Timeouts.ResponseHeader); err == nil {
+		opts.ResponseHeaderTimeout = timeout
+	}
+	if timeout, err := time.ParseDuration(c.Timeouts.IdleRead); err == nil {
+		opts.IdleReadTimeout = timeout
+	}
+	opts.Proxy = c.Proxy
+	opts.TLS = jellyfin.TLSOptions{
+		CAFile:             c.TLS.CAFile,
+		CertFile:           c.TLS.CertFile,
+		KeyFile:            c.TLS.KeyFile,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+	}
+	return opts
 }
 
-type Response struct {
-	Items []JellyfinItem
+const defaultStallTimeout = 30 * time.Second
+
+// stallTimeout returns after how long without receiving any byte a download
+// is restarted.
+func (c *Config) stallTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(c.Timeouts.Stall); err == nil {
+		return timeout
+	}
+	return defaultStallTimeout
 }
 
-type Query struct {
-	ParentId string   `url:"parentId,omitempty"`
-	Ids      []string `url:"ids,omitempty"`
+const pageSize = 100
+
+// childsQuery requests no Fields: the browser only needs the base ones, the
+// sizes are fetched by getItems for the selection summary and the downloads.
+func childsQuery(parentId string) jellyfin.Query {
+	q := jellyfin.Query{ParentId: parentId}
+	if parentId == playlistsId {
+		q = jellyfin.Query{IncludeItemTypes: []string{"Playlist", "BoxSet"}, Recursive: true}
+	}
+	q.EnableImages = jellyfin.Bool(false)
+	q.EnableUserData = jellyfin.Bool(false)
+	return q
 }
 
-const itemsUrl = "/Users/{userId}/Items"
-const downloadUrl = "/Items/{id}/Download"
-const auth = "MediaBrowser Client=\"Download Client\", Device=\"Linux\", DeviceId=\"PlRvNOqV9GYvBBUssdhY\", Version=\"1.0\", Token=\"{token}\""
+func getChildsPage(ctx context.Context, parentId string, start int, config *Config) jellyfin.Response {
+	q := childsQuery(parentId)
+	q.StartIndex = start
+	q.Limit = pageSize
+	res := queryItems(ctx, q, config)
+	if config.cache.isOffline() && start == 0 {
+		return getOfflineChilds(parentId, config)
+	}
+	if res.TotalRecordCount < start+len(res.Items) || len(res.Items) == 0 {
+		res.TotalRecordCount = start + len(res.Items)
+	}
 
-var client = &http.Client{}
+	if parentId == "" && len(res.Items) != 0 && start+len(res.Items) == res.TotalRecordCount {
+		res.Items = append(res.Items, playlistsFolder)
+		res.TotalRecordCount++
+	}
+	return res
+}
 
-func getChilds(parentId string, config *Config) Response {
-	if parentId == "" {
-		return queryItems(nil, config)
-	} else {
-		return queryItems(&Query{ParentId: parentId}, config)
+func getChilds(ctx context.Context, parentId string, config *Config) jellyfin.Response {
+	var res jellyfin.Response
+	for {
+		page := getChildsPage(ctx, parentId, len(res.Items), config)
+		res.Items = append(res.Items, page.Items...)
+		res.TotalRecordCount = page.TotalRecordCount
+		if len(page.Items) == 0 || ctx.Err() != nil || len(res.Items) >= res.TotalRecordCount {
+			return res
+		}
 	}
 }
 
-func getItems(items []string, config *Config) Response {
-	var res Response
+func getItems(ctx context.Context, items []string, config *Config) jellyfin.Response {
+	var res jellyfin.Response
+	if config.cache.isOffline() {
+		// Without their sizes, which the browser does not fetch
+		for _, id := range items {
+			if item, ok := config.cache.findItem(id); ok {
+				res.Items = append(res.Items, item)
+			}
+		}
+		return res
+	}
 	chunked := chunkBy(items, 200)
 	for _, v := range chunked {
-		current := queryItems(&Query{Ids: v}, config)
+		current := queryItems(ctx, jellyfin.Query{Ids: v, Fields: []string{"MediaSources"}}, config)
 		res.Items = append(res.Items, current.Items...)
 	}
 	return res
 }
 
-type incorrectAPIEndPointMsg string //With the message if there is
+var detailFields = []string{"Overview", "MediaSources", "MediaStreams"}
 
-func queryItems(q *Query, config *Config) Response {
-	var userId = config.UserId
-	if userId == "" {
-		userId = "0"
-	}
-	requestUrl := strings.ReplaceAll(config.APIEndpoint+itemsUrl, "{userId}", userId)
-	if q != nil {
-		queryString, _ := query.Values(q)
-		requestUrl += "?" + queryString.Encode()
-	}
-	program.Send(infoMsg{"Fetching " + requestUrl})
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	checkError(err)
-	req.Header.Add("X-Emby-Authorization", strings.ReplaceAll(auth, "{token}", config.APIKey))
-	resp, err2 := client.Do(req)
-
-	if err2 != nil {
-		switch err2 := err2.(type) {
-		case *url.Error:
-			program.Send(incorrectAPIEndPointMsg("Incorrect Endpoint"))
-			program.Send(infoMsg{""})
-			return Response{}
-		default:
-			checkError(err2)
-		}
+func getDetails(ctx context.Context, id string, config *Config) (jellyfin.Item, bool) {
+	if config.cache.isOffline() {
+		return config.cache.findItem(id)
 	}
-
-	checkResp(resp)
-	data, err3 := ioutil.ReadAll(resp.Body)
-	checkError(err3)
-	body := Response{}
-	json.Unmarshal(data, &body)
-	program.Send(infoMsg{""})
-	return body
+	client, err := config.jellyfinClient()
+	if err != nil {
+		handleError(err, config)
+		return config.cache.findItem(id)
+	}
+	item, err := client.Item(ctx, id, detailFields...)
+	if err != nil {
+		handleError(err, config)
+		return config.cache.findItem(id)
+	}
+	return item, true
 }
 
-var red = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+type incorrectAPIEndPointMsg string //With the message if there is
+
+func queryItems(ctx context.Context, q jellyfin.Query, config *Config) jellyfin.Response {
+	if config.cache.isOffline() {
+		return jellyfin.Response{}
+	}
+	client, err := config.jellyfinClient()
+	if err != nil {
+		handleError(err, config)
+		return jellyfin.Response{}
+	}
+	res, err := client.Items(ctx, q)
+	if err != nil {
+		handleError(err, config)
+		return jellyfin.Response{}
+	}
+	return res
+}
 
 type incorrectAPIKeyMsg string
 type incorrectUserIdMsg string
 
-func checkResp(resp *http.Response) {
-	if resp.StatusCode != 200 {
-		bodyText, err := ioutil.ReadAll(resp.Body)
-		checkError(err)
-		if resp.StatusCode == 401 {
-			program.Send(incorrectAPIKeyMsg("Incorrect API key"))
-			return
-		}
+// certificateErrorMsg reports a TLS problem, which no other endpoint, key or
+// user fixes.
+type certificateErrorMsg string
 
-		if resp.StatusCode == 400 {
-			program.Send(incorrectUserIdMsg("Incorrect UserId"))
-			return
-		}
+// transportErrorMsg reports TLS or proxy settings that cannot be loaded.
+type transportErrorMsg string
 
-		body := string(bodyText)
-		if body != "" {
-			body = "null"
+// handleError reports a failed request to the user.
+func handleError(err error, config *Config) {
+	if !errors.Is(err, context.Canceled) {
+		logger.Error("request error", "error", err)
+	}
+	var unreachable *jellyfin.UnreachableError
+	var certificate *jellyfin.CertificateError
+	switch {
+	case errors.Is(err, context.Canceled):
+	case errors.Is(err, jellyfin.ErrUnauthorized):
+		config.send(incorrectAPIKeyMsg("Incorrect API key"))
+	case errors.Is(err, jellyfin.ErrBadUserId):
+		config.send(incorrectUserIdMsg("Incorrect UserId"))
+	case errors.Is(err, errInvalidTransport):
+		config.send(transportErrorMsg(err.Error()))
+	case errors.As(err, &certificate):
+		config.send(certificateErrorMsg("certificate error: " + certificate.Err.Error()))
+	case errors.As(err, &unreachable):
+		if config.cache.goOffline() {
+			config.send(infoMsg{"Server unreachable, browsing offline from the cache"})
+			return
 		}
-		checkError(fmt.Errorf("Server responded with error code %d when calling %s \nbody: %s", resp.StatusCode, resp.Request.URL, body))
+		config.send(incorrectAPIEndPointMsg("Incorrect Endpoint"))
+		config.send(infoMsg{""})
+	default:
+		config.send(infoMsg{errorStyle.Render(err.Error())})
 	}
 }
 
-type startDownloadingItemMsg string
+type startDownloadingItemMsg struct {
+	Id   string
+	dest string
+}
 type downloadStartedMsg struct {
 	Id   string
 	resp *grab.Response
 }
 
-var grabClient = grab.NewClient()
-
-func downloadFile(id, dest string, config *Config) (string, error) {
-	program.Send(startDownloadingItemMsg(id))
-
-	req, err := grab.NewRequest(dest, strings.ReplaceAll(config.APIEndpoint+downloadUrl, "{id}", id))
-	checkError(err)
-	req.HTTPRequest.Header.Add("X-Emby-Authorization", strings.ReplaceAll(auth, "{token}", config.APIKey))
-	resp := grabClient.Do(req)
-	program.Send(downloadStartedMsg{id, resp})
+func startDownload(id, dest string, config *Config) tea.Cmd {
+	return func() tea.Msg {
+		client, err := config.jellyfinClient()
+		if err != nil {
+			return downloadFailedMsg{id, err.Error(), err, nil}
+		}
+		resp, err := client.Download(context.Background(), id, dest)
+		if err != nil {
+			return downloadFailedMsg{id, err.Error(), err, nil}
+		}
+		return downloadStartedMsg{id, resp}
+	}
+}
 
-	<-resp.Done
+func waitDownload(id string, resp *grab.Response) tea.Cmd {
+	return func() tea.Msg {
+		<-resp.Done
 
-	if err := resp.Err(); err != nil {
-		os.Remove(resp.Filename)
-		return "", err
+		if err := resp.Err(); err != nil {
+			return downloadFailedMsg{id, err.Error(), err, resp}
+		}
+		return downloadCompletedMsg{id, resp.Filename}
 	}
-
-	return resp.Filename, nil
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
+)
+
+type askRuleMsg struct {
+	id, name string
+}
+
+// ruleChanges is what the "next N unwatched episodes" rules change in the
+// selection.
+type ruleChanges struct {
+	add, remove []string
+}
+
+type rulesAppliedMsg ruleChanges
+
+// evaluateRules selects the next unplayed episodes after the user's progress
+// of every series of rules, and drops the watched ones. It only reads the
+// server, the changes are applied to the selection by Update.
+func evaluateRules(rules map[string]int, config *Config) ruleChanges {
+	var changes ruleChanges
+	for seriesId, count := range rules {
+		episodes := queryItems(context.Background(), jellyfin.Query{
+			ParentId:         seriesId,
+			Recursive:        true,
+			IncludeItemTypes: []string{"Episode"},
+			SortBy:           []string{"ParentIndexNumber", "IndexNumber"},
+		}, config).Items
+
+		var progress int
+		for i, episode := range episodes {
+			if episode.UserData.Played {
+				progress = i + 1
+			}
+		}
+
+		var kept int
+		for i, episode := range episodes {
+			switch {
+			case i >= progress && !episode.UserData.Played && kept < count:
+				kept++
+				changes.add = append(changes.add, episode.Id)
+			case episode.UserData.Played:
+				changes.remove = append(changes.remove, episode.Id)
+			}
+		}
+	}
+	return changes
+}
+
+// apply changes the selection, it returns whether it changed.
+func (c ruleChanges) apply(selected *Set) bool {
+	var changed bool
+	for _, id := range c.add {
+		if !selected.Contains(id) {
+			selected.Add(id)
+			changed = true
+		}
+	}
+	for _, id := range c.remove {
+		if selected.Contains(id) {
+			selected.Remove(id)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// copyRules snapshots the rules for a command, which must not read the
+// config while Update changes it.
+func copyRules(config *Config) map[string]int {
+	rules := make(map[string]int, len(config.NextUnwatched))
+	for id, count := range config.NextUnwatched {
+		rules[id] = count
+	}
+	return rules
+}
+
+func applyRulesCmd(config *Config) tea.Cmd {
+	rules := copyRules(config)
+	return func() tea.Msg {
+		return rulesAppliedMsg(evaluateRules(rules, config))
+	}
+}
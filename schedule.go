@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduleConfig restricts the downloads to time windows. Without any window
+// the downloads run at any time. OnClose is "pause" to stop the running
+// downloads when their window closes, they resume in the next one, or
+// "finish" to let them end.
+type ScheduleConfig struct {
+	Windows []ScheduleWindow
+	OnClose string
+}
+
+// ScheduleWindow is open from Start to End, "HH:MM" times, on the Days it
+// lists: "mon" to "sun", "weekdays" or "weekends", every day when empty. A
+// window ending before it starts ends the next day, both times left empty
+// cover the whole day. RateLimit caps the bandwidth, as "5MB" per second.
+type ScheduleWindow struct {
+	Days      []string
+	Start     string
+	End       string
+	RateLimit string
+}
+
+var weekdays = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// parseClock returns the time of the day "HH:MM" as a duration since
+// midnight, an empty string is midnight.
+func parseClock(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var hours, minutes int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil || hours < 0 || hours > 24 || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// parseByteSize parses a size such as "500KB" or "2.5MB", in the SI units of
+// ByteCountSI. An empty string is 0.
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "/S")
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := 1.0
+	for i, unit := range []string{"KB", "MB", "GB", "TB"} {
+		if strings.HasSuffix(s, unit) {
+			s = strings.TrimSuffix(s, unit)
+			for j := 0; j <= i; j++ {
+				multiplier *= 1000
+			}
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "B")), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * multiplier), nil
+}
+
+func (w ScheduleWindow) validate() error {
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	_, err := parseByteSize(w.RateLimit)
+	return err
+}
+
+func (w ScheduleWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		for _, d := range weekdays[strings.ToLower(name)] {
+			if d == day {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// openAt tells if the window is open at t. A window crossing midnight belongs
+// to the day it starts.
+func (w ScheduleWindow) openAt(t time.Time) bool {
+	if w.validate() != nil {
+		return false
+	}
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+	today := midnight(t)
+	since := t.Sub(today)
+	if start < end {
+		return w.onDay(t.Weekday()) && since >= start && since < end
+	}
+	yesterday := today.AddDate(0, 0, -1).Weekday()
+	return (w.onDay(t.Weekday()) && since >= start) || (w.onDay(yesterday) && since < end)
+}
+
+// rate returns the bandwidth cap of the window in bytes per second, 0 when
+// it has none.
+func (w ScheduleWindow) rate() int64 {
+	rate, _ := parseByteSize(w.RateLimit)
+	return rate
+}
+
+func (s ScheduleConfig) validate() error {
+	for i, w := range s.Windows {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("window %d: %w", i+1, err)
+		}
+	}
+	if s.OnClose != "" && s.OnClose != "pause" && s.OnClose != "finish" {
+		return fmt.Errorf("OnClose is %q, expected pause or finish", s.OnClose)
+	}
+	return nil
+}
+
+// active returns the first window open at t. Downloads are always allowed
+// when there is no window.
+func (s ScheduleConfig) active(t time.Time) (ScheduleWindow, bool) {
+	if len(s.Windows) == 0 {
+		return ScheduleWindow{}, true
+	}
+	for _, w := range s.Windows {
+		if w.openAt(t) {
+			return w, true
+		}
+	}
+	return ScheduleWindow{}, false
+}
+
+// nextOpen returns when the next window opens after t, the zero time when
+// none ever does.
+func (s ScheduleConfig) nextOpen(t time.Time) time.Time {
+	var next time.Time
+	for d := 0; d <= 7; d++ {
+		day := midnight(t).AddDate(0, 0, d)
+		for _, w := range s.Windows {
+			if w.validate() != nil || !w.onDay(day.Weekday()) {
+				continue
+			}
+			start, _ := parseClock(w.Start)
+			if at := day.Add(start); at.After(t) && (next.IsZero() || at.Before(next)) {
+				next = at
+			}
+		}
+	}
+	return next
+}
+
+// pauseOnClose tells if the running downloads stop when their window closes.
+func (s ScheduleConfig) pauseOnClose() bool {
+	return s.OnClose != "finish"
+}
+
+// bandwidthLimiter caps the total speed of the downloads, it is shared by all
+// of them and its rate can change while they run.
+type bandwidthLimiter struct {
+	mu   sync.Mutex
+	rate int64 // bytes per second, 0 is unlimited
+	next time.Time
+}
+
+var bandwidth = &bandwidthLimiter{}
+
+func (l *bandwidthLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rate != l.rate {
+		l.rate = rate
+		l.next = time.Time{}
+	}
+}
+
+// WaitN implements grab.RateLimiter, it waits until n more bytes fit in the
+// rate.
+func (l *bandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pausedView tells until when the schedule holds the downloads back.
+func pausedView(until time.Time) string {
+	if until.IsZero() {
+		return "⏸ Paused: no download window is open"
+	}
+	format := "15:04"
+	if midnight(until) != midnight(time.Now()) {
+		format = "Mon 15:04"
+	}
+	return "⏸ Paused until " + until.Format(format)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	schedule := ScheduleConfig{Windows: []ScheduleWindow{
+		{Days: []string{"weekdays"}, Start: "23:00", End: "07:00", RateLimit: "2MB"},
+		{Days: []string{"weekends"}},
+	}}
+	// 2024-01-05 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+
+	for _, test := range []struct {
+		t    time.Time
+		open bool
+		rate int64
+	}{
+		{at(4, 22, 59), false, 0},
+		{at(4, 23, 0), true, 2000000},
+		{at(5, 6, 59), true, 2000000},
+		{at(5, 7, 0), false, 0},
+		{at(5, 23, 30), true, 2000000}, // Friday night, still the weekday window
+		{at(6, 12, 0), true, 0},
+		{at(8, 6, 0), false, 0}, // Monday morning, the Sunday night belongs to the weekend
+		{at(8, 12, 0), false, 0},
+	} {
+		window, open := schedule.active(test.t)
+		if open != test.open || window.rate() != test.rate {
+			t.Errorf("%s: got %t %d, want %t %d", test.t, open, window.rate(), test.open, test.rate)
+		}
+	}
+
+	if next := schedule.nextOpen(at(8, 12, 0)); !next.Equal(at(8, 23, 0)) {
+		t.Errorf("next window at %s", next)
+	}
+	if next := (ScheduleConfig{Windows: []ScheduleWindow{{Days: []string{"mon"}, Start: "01:00", End: "02:00"}}}).nextOpen(at(8, 3, 0)); !next.Equal(at(15, 1, 0)) {
+		t.Errorf("next week window at %s", next)
+	}
+	if _, open := (ScheduleConfig{}).active(at(8, 12, 0)); !open {
+		t.Error("no window should allow the downloads at any time")
+	}
+
+	if err := (ScheduleConfig{Windows: []ScheduleWindow{{Start: "25:00"}}}).validate(); err == nil {
+		t.Error("an invalid time should be reported")
+	}
+	if err := (ScheduleConfig{Windows: []ScheduleWindow{{Days: []string{"someday"}}}}).validate(); err == nil {
+		t.Error("an unknown day should be reported")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for s, want := range map[string]int64{"": 0, "512": 512, "500KB": 500000, "2.5MB": 2500000, "1gb/s": 1000000000} {
+		if got, err := parseByteSize(s); err != nil || got != want {
+			t.Errorf("%q: got %d, %v, want %d", s, got, err, want)
+		}
+	}
+	if _, err := parseByteSize("fast"); err == nil {
+		t.Error("an invalid size should be reported")
+	}
+}
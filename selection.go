@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
+)
+
+type selectionSummary struct {
+	count      int
+	selected   int64
+	downloaded int64
+}
+
+type selectionSummaryMsg selectionSummary
+
+func (s selectionSummary) String() string {
+	return fmt.Sprintf("%d items · %s selected · %s already downloaded", s.count, ByteCountSI(s.selected), ByteCountSI(s.downloaded))
+}
+
+func getSelectionSummary(items []jellyfin.Item, config *Config) selectionSummary {
+	var summary selectionSummary
+	for _, v := range items {
+		if v.IsFolder || !config.Selected.Contains(v.Id) {
+			continue
+		}
+		summary.count++
+		summary.selected += v.Size()
+		if _, ok := config.Downloaded[v.Id]; ok {
+			summary.downloaded += v.Size()
+		}
+	}
+	return summary
+}
+
+func computeSelectionSummary(config *Config) tea.Cmd {
+	return func() tea.Msg {
+		if config.Selected.IsEmpty() {
+			return selectionSummaryMsg{}
+		}
+		items := getItems(context.Background(), config.Selected.Values(), config).Items
+		return selectionSummaryMsg(getSelectionSummary(items, config))
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"log"
 	"os"
 	"path"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
 )
 
 type Config struct {
@@ -14,6 +18,71 @@ type Config struct {
 	UserId           string
 	DownloadLocation string
 	APIEndpoint      string
+	WritePlaylists   bool
+	NextUnwatched    map[string]int
+	CacheTTL         string
+	Retry            RetryConfig
+	Timeouts         TimeoutConfig
+	TLS              TLSConfig
+	Headers          map[string]string
+	BasicAuth        BasicAuthConfig
+	Proxy            string
+	DeviceId         string
+	DeviceName       string
+	Keys             KeysConfig
+	Theme            string
+	HideHelpFooter   bool
+	DisableMouse     bool
+	Hooks            HooksConfig
+	Webhooks         []WebhookConfig
+	Schedule         ScheduleConfig
+	Queue            QueueConfig
+
+	client    *jellyfin.Client
+	clientErr error
+	cache     *itemCache
+	notify    func(tea.Msg)
+}
+
+type RetryConfig struct {
+	MaxAttempts        int
+	BaseDelay          string
+	MaxDelay           string
+	RetryableStatus    []int
+	RetryNetworkErrors *bool
+}
+
+type TimeoutConfig struct {
+	Connect        string
+	ResponseHeader string
+	IdleRead       string
+	Stall          string
+}
+
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+type BasicAuthConfig struct {
+	Username string
+	Password string
+}
+
+type KeysConfig struct {
+	Preset   string
+	Bindings map[string][]string
+}
+
+// HooksConfig holds the shell commands run when a download ends, and when
+// the queue has nothing left to download.
+type HooksConfig struct {
+	Completed string
+	Failed    string
+	Drained   string
+	Timeout   string
 }
 
 type writedConfig struct {
@@ -23,6 +92,25 @@ type writedConfig struct {
 	UserId           string
 	DownloadLocation string
 	APIEndpoint      string
+	WritePlaylists   bool
+	NextUnwatched    map[string]int
+	CacheTTL         string
+	Retry            RetryConfig
+	Timeouts         TimeoutConfig
+	TLS              TLSConfig
+	Headers          map[string]string
+	BasicAuth        BasicAuthConfig
+	Proxy            string
+	DeviceId         string
+	DeviceName       string
+	Keys             KeysConfig
+	Theme            string
+	HideHelpFooter   bool
+	DisableMouse     bool
+	Hooks            HooksConfig
+	Webhooks         []WebhookConfig
+	Schedule         ScheduleConfig
+	Queue            QueueConfig
 }
 
 func getConfigFilePath() string {
@@ -40,6 +128,25 @@ func writeConfig(conf Config) {
 		conf.UserId,
 		conf.DownloadLocation,
 		conf.APIEndpoint,
+		conf.WritePlaylists,
+		conf.NextUnwatched,
+		conf.CacheTTL,
+		conf.Retry,
+		conf.Timeouts,
+		conf.TLS,
+		conf.Headers,
+		conf.BasicAuth,
+		conf.Proxy,
+		conf.DeviceId,
+		conf.DeviceName,
+		conf.Keys,
+		conf.Theme,
+		conf.HideHelpFooter,
+		conf.DisableMouse,
+		conf.Hooks,
+		conf.Webhooks,
+		conf.Schedule,
+		conf.Queue,
 	}
 	b, err := json.Marshal(writedConf)
 	if err != nil {
@@ -69,13 +176,41 @@ func getConfig() *Config {
 	if conf.Downloaded == nil {
 		conf.Downloaded = make(map[string]string)
 	}
+	if conf.NextUnwatched == nil {
+		conf.NextUnwatched = make(map[string]int)
+	}
 
-	return &Config{
-		selected,
-		conf.Downloaded,
-		conf.APIKey,
-		conf.UserId,
-		conf.DownloadLocation,
-		conf.APIEndpoint,
+	config := &Config{
+		Selected:         selected,
+		Downloaded:       conf.Downloaded,
+		APIKey:           conf.APIKey,
+		UserId:           conf.UserId,
+		DownloadLocation: conf.DownloadLocation,
+		APIEndpoint:      conf.APIEndpoint,
+		WritePlaylists:   conf.WritePlaylists,
+		NextUnwatched:    conf.NextUnwatched,
+		CacheTTL:         conf.CacheTTL,
+		Retry:            conf.Retry,
+		Timeouts:         conf.Timeouts,
+		TLS:              conf.TLS,
+		Headers:          conf.Headers,
+		BasicAuth:        conf.BasicAuth,
+		Proxy:            conf.Proxy,
+		DeviceId:         conf.DeviceId,
+		DeviceName:       conf.DeviceName,
+		Keys:             conf.Keys,
+		Theme:            conf.Theme,
+		HideHelpFooter:   conf.HideHelpFooter,
+		DisableMouse:     conf.DisableMouse,
+		Hooks:            conf.Hooks,
+		Webhooks:         conf.Webhooks,
+		Schedule:         conf.Schedule,
+		Queue:            conf.Queue,
+	}
+
+	if config.DeviceId == "" {
+		config.DeviceId = jellyfin.NewDeviceId()
+		writeConfig(*config)
 	}
+	return config
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Theme names the colours of the interface by what they mean. A theme file is
+// the JSON of a Theme, the colours it leaves empty are taken from its Base.
+type Theme struct {
+	Base         string
+	Accent       string // titles and prompts
+	Muted        string // secondary text
+	Text         string // episode and movie names
+	Highlight    string // series, seasons and labels
+	Selected     string
+	Downloaded   string
+	Failed       string
+	Progress     string // spinner and download state
+	ProgressFrom string // gradient of the progress bars
+	ProgressTo   string
+	Border       string
+	Button       string
+	ButtonActive string
+	ButtonText   string
+	Monochrome   bool // markers instead of colours
+}
+
+var builtinThemes = map[string]Theme{
+	"default": {
+		Accent:       "220",
+		Muted:        "8",
+		Text:         "190",
+		Highlight:    "14",
+		Selected:     "12",
+		Downloaded:   "10",
+		Failed:       "160",
+		Progress:     "205",
+		ProgressFrom: "#5A56E0",
+		ProgressTo:   "#EE6FF8",
+		Border:       "62",
+		Button:       "8",
+		ButtonActive: "4",
+		ButtonText:   "#ffffff",
+	},
+	"light": {
+		Accent:       "130",
+		Muted:        "242",
+		Text:         "22",
+		Highlight:    "24",
+		Selected:     "21",
+		Downloaded:   "28",
+		Failed:       "124",
+		Progress:     "90",
+		ProgressFrom: "#3B38A8",
+		ProgressTo:   "#A0329E",
+		Border:       "61",
+		Button:       "250",
+		ButtonActive: "25",
+		ButtonText:   "#000000",
+	},
+	"monochrome": {Monochrome: true},
+}
+
+// loadTheme returns the built-in theme called name, or reads the theme file at
+// the path name. NO_COLOR always selects the monochrome theme.
+func loadTheme(name string) (Theme, error) {
+	if os.Getenv("NO_COLOR") != "" {
+		return builtinThemes["monochrome"], nil
+	}
+	if name == "" {
+		name = "default"
+	}
+	if theme, ok := builtinThemes[name]; ok {
+		return theme, nil
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return builtinThemes["default"], fmt.Errorf("unknown theme %q: %w", name, err)
+	}
+	var theme Theme
+	if err := json.Unmarshal(b, &theme); err != nil {
+		return builtinThemes["default"], fmt.Errorf("theme %s: %w", name, err)
+	}
+	if theme.Base == "" {
+		theme.Base = "default"
+	}
+	base, ok := builtinThemes[theme.Base]
+	if !ok {
+		return builtinThemes["default"], fmt.Errorf("theme %s: unknown base %q", name, theme.Base)
+	}
+	return theme.over(base), nil
+}
+
+// over fills the colours t leaves empty with the ones of base.
+func (t Theme) over(base Theme) Theme {
+	fill := func(color *string, fallback string) {
+		if *color == "" {
+			*color = fallback
+		}
+	}
+	fill(&t.Accent, base.Accent)
+	fill(&t.Muted, base.Muted)
+	fill(&t.Text, base.Text)
+	fill(&t.Highlight, base.Highlight)
+	fill(&t.Selected, base.Selected)
+	fill(&t.Downloaded, base.Downloaded)
+	fill(&t.Failed, base.Failed)
+	fill(&t.Progress, base.Progress)
+	fill(&t.ProgressFrom, base.ProgressFrom)
+	fill(&t.ProgressTo, base.ProgressTo)
+	fill(&t.Border, base.Border)
+	fill(&t.Button, base.Button)
+	fill(&t.ButtonActive, base.ButtonActive)
+	fill(&t.ButtonText, base.ButtonText)
+	t.Monochrome = t.Monochrome || base.Monochrome
+	return t
+}
+
+var theme Theme
+
+var (
+	docStyle             lipgloss.Style
+	unSelect             lipgloss.Style
+	focusedTitleStyle    lipgloss.Style
+	selectedItem         lipgloss.Style
+	downloadedItem       lipgloss.Style
+	classicItem          = lipgloss.NewStyle()
+	errorStyle           lipgloss.Style
+	promptStyle          lipgloss.Style
+	progressStyle        lipgloss.Style
+	successStyle         lipgloss.Style
+	textStyle            lipgloss.Style
+	highlightStyle       lipgloss.Style
+	mutedStyle           lipgloss.Style
+	detailTitleStyle     lipgloss.Style
+	detailLabelStyle     lipgloss.Style
+	detailMutedStyle     lipgloss.Style
+	buttonTextStyle      lipgloss.Style
+	buttonStyle          = lipgloss.NewStyle().MarginLeft(1).PaddingRight(1).PaddingLeft(1)
+	notActiveButtonStyle lipgloss.Style
+	activeButtonStyle    lipgloss.Style
+)
+
+func init() {
+	applyTheme(builtinThemes["default"])
+}
+
+// applyTheme sets the styles of the interface. A monochrome theme drops every
+// colour, the states are then told apart with markers.
+func applyTheme(t Theme) {
+	theme = t
+	if t.Monochrome {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	color := func(c string) lipgloss.TerminalColor {
+		if t.Monochrome || c == "" {
+			return lipgloss.NoColor{}
+		}
+		return lipgloss.Color(c)
+	}
+
+	docStyle = lipgloss.NewStyle().Margin(1, 2).
+		Border(lipgloss.RoundedBorder()).BorderForeground(color(t.Border))
+	unSelect = lipgloss.NewStyle().Margin(0, 1).Foreground(color(t.Accent))
+	focusedTitleStyle = list.DefaultStyles().Title
+	if t.Monochrome {
+		focusedTitleStyle = lipgloss.NewStyle().Padding(0, 1).Bold(true).Underline(true)
+	}
+	selectedItem = lipgloss.NewStyle().Foreground(color(t.Selected))
+	downloadedItem = lipgloss.NewStyle().Foreground(color(t.Downloaded))
+	errorStyle = lipgloss.NewStyle().Foreground(color(t.Failed))
+	promptStyle = lipgloss.NewStyle().Foreground(color(t.Accent))
+	progressStyle = lipgloss.NewStyle().Foreground(color(t.Progress))
+	successStyle = lipgloss.NewStyle().Foreground(color(t.Downloaded))
+	textStyle = lipgloss.NewStyle().Foreground(color(t.Text))
+	highlightStyle = lipgloss.NewStyle().Foreground(color(t.Highlight))
+	mutedStyle = lipgloss.NewStyle().Foreground(color(t.Muted))
+	detailTitleStyle = lipgloss.NewStyle().Bold(true).Foreground(color(t.Accent))
+	detailLabelStyle = highlightStyle
+	detailMutedStyle = mutedStyle
+	buttonTextStyle = lipgloss.NewStyle().Foreground(color(t.ButtonText))
+	notActiveButtonStyle = buttonStyle.Copy().Background(color(t.Button))
+	activeButtonStyle = buttonStyle.Copy().Background(color(t.ButtonActive))
+}
+
+// progressOption colours the progress bars.
+func progressOption() progress.Option {
+	if theme.Monochrome {
+		return progress.WithColorProfile(termenv.Ascii)
+	}
+	return progress.WithGradient(theme.ProgressFrom, theme.ProgressTo)
+}
+
+// itemMarker tells the state of an item apart without colours.
+func itemMarker(selected, downloaded bool) string {
+	if !theme.Monochrome {
+		return ""
+	}
+	switch {
+	case downloaded:
+		return "✔ "
+	case selected:
+		return "● "
+	default:
+		return "  "
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTheme(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	file := filepath.Join(t.TempDir(), "theme.json")
+	if err := os.WriteFile(file, []byte(`{"Base": "light", "Selected": "#ff0000"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	theme, err := loadTheme(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.Selected != "#ff0000" || theme.Downloaded != builtinThemes["light"].Downloaded {
+		t.Errorf("unexpected theme %+v", theme)
+	}
+
+	if _, err := loadTheme("missing"); err == nil {
+		t.Error("an unknown theme should be reported")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if theme, _ := loadTheme("light"); !theme.Monochrome {
+		t.Error("NO_COLOR should select the monochrome theme")
+	}
+}
@@ -10,12 +10,13 @@ import (
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-)
 
-var errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("160"))
+	"jellyfindl/jellyfin"
+)
 
 func checkError(err error) {
 	if err != nil {
+		logger.Error("fatal error", "error", err)
 		program.Kill()
 		fmt.Println("Error while running program:", errorStyle.Render(err.Error()))
 		//os.Exit(1)
@@ -44,6 +45,20 @@ func isGoodContent(items1 []list.Item, items2 []list.Item) bool {
 	return true
 }
 
+func isSameItems(items1 []jellyfin.Item, items2 []jellyfin.Item) bool {
+	if len(items1) < len(items2) {
+		return false
+	}
+
+	for i, value := range items2 {
+		if value.Id != items1[i].Id || value.Name != items1[i].Name {
+			return false
+		}
+	}
+
+	return true
+}
+
 func printStruct(val interface{}) {
 	fmt.Printf("%+v\n", val)
 }
@@ -131,6 +146,12 @@ func (m model) callDownloadUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+func (m model) callHistoryUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.historyModel, cmd = m.historyModel.Update(msg)
+	return m, cmd
+}
+
 func (m downloadModel) getItem(id string) downloadItem {
 	for _, v := range m.list.Items() {
 		if v.(downloadItem).id == id {
@@ -163,6 +184,10 @@ func (s downloadItemSorter) Less(i, j int) bool {
 	item1 := s[i].(downloadItem)
 	item2 := s[j].(downloadItem)
 
+	if less, ok := queueLess(item1, item2); ok {
+		return less
+	}
+
 	if (item1.fail != "") != (item2.fail != "") {
 		if item1.fail != "" {
 			return true
@@ -215,7 +240,7 @@ func sortList(items []list.Item) {
 func (m downloadModel) getNext() (string, string) {
 	for _, i := range m.list.Items() {
 		item := i.(downloadItem)
-		if !item.downloadCompleted {
+		if !item.downloadCompleted && !item.paused && item.fail == "" {
 			return item.id, getDownloadLocation(item.jellyfinItem)
 		}
 	}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"jellyfindl/jellyfin"
+)
+
+const (
+	webhookQueueStarted  = "queue-started"
+	webhookCompleted     = hookCompleted
+	webhookFailed        = hookFailed
+	webhookQueueFinished = "queue-finished"
+
+	webhookTimeout = 30 * time.Second
+)
+
+var webhookEvents = []string{webhookQueueStarted, webhookCompleted, webhookFailed, webhookQueueFinished}
+
+// WebhookConfig is an URL receiving a POST for the listed events, all of them
+// when Events is empty. Format is json for the raw payload, slack or discord,
+// and Template, a Go template of the payload, overrides it.
+type WebhookConfig struct {
+	URL      string
+	Events   []string
+	Format   string
+	Template string
+	Headers  map[string]string
+}
+
+// Text sums up the event for a human.
+func (p hookPayload) Text() string {
+	title := p.Name
+	if p.SeriesName != "" {
+		title = fmt.Sprintf("%s %s %d. %s", p.SeriesName, p.SeasonName, p.EpisodeNumber, p.Name)
+	}
+	switch p.Event {
+	case webhookQueueStarted:
+		return fmt.Sprintf("jellyfindl started downloading %d items", p.Queued)
+	case webhookCompleted:
+		return fmt.Sprintf("jellyfindl downloaded %s (%s)", title, ByteCountSI(p.Size))
+	case webhookFailed:
+		return fmt.Sprintf("jellyfindl failed to download %s: %s", title, p.Error)
+	case webhookQueueFinished:
+		return fmt.Sprintf("jellyfindl finished downloading: %d downloaded, %d failed", p.Completed, p.Failed)
+	}
+	return "jellyfindl " + p.Event
+}
+
+var webhookTemplates = map[string]string{
+	"slack":   `{"text": {{json .Text}}}`,
+	"discord": `{"content": {{json .Text}}}`,
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (w WebhookConfig) wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// body renders the payload in the format of the webhook.
+func (w WebhookConfig) body(payload hookPayload) ([]byte, error) {
+	text := w.Template
+	if text == "" {
+		switch w.Format {
+		case "", "json":
+			return json.Marshal(payload)
+		default:
+			var ok bool
+			if text, ok = webhookTemplates[w.Format]; !ok {
+				return nil, fmt.Errorf("unknown webhook format %q", w.Format)
+			}
+		}
+	}
+	tmpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, payload); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// webhookHost names a webhook in the messages without leaking the token which
+// is often part of its URL.
+func webhookHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "webhook"
+}
+
+// sendWebhook posts the payload, retrying with the download retry policy.
+func sendWebhook(ctx context.Context, w WebhookConfig, payload hookPayload, config *Config) error {
+	body, err := w.body(payload)
+	if err != nil {
+		return err
+	}
+	// The TLS settings are for the Jellyfin server, the webhooks are verified
+	// with the system certificates and never see its client certificate
+	opts := config.transportOptions()
+	opts.TLS = jellyfin.TLSOptions{}
+	client, err := jellyfin.NewHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	client.Timeout = webhookTimeout
+
+	policy := config.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(ctx, client, w, body)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+			return err
+		}
+		logger.Warn("webhook failed, retrying", "host", webhookHost(w.URL), "event", payload.Event, "attempt", attempt, "error", err)
+		select {
+		case <-time.After(policy.Backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func postWebhook(ctx context.Context, client *http.Client, w WebhookConfig, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "jellyfindl/"+appVersion())
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &jellyfin.StatusError{StatusCode: resp.StatusCode, URL: webhookHost(w.URL), Body: strings.TrimSpace(string(b))}
+	}
+	return nil
+}
+
+type webhookDoneMsg struct {
+	host, event string
+	err         error
+}
+
+// notifyWebhooks sends the payload to every webhook listening to its event.
+func notifyWebhooks(payload hookPayload, config *Config) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, w := range config.Webhooks {
+		if !w.wants(payload.Event) {
+			continue
+		}
+		w := w
+		cmds = append(cmds, func() tea.Msg {
+			err := sendWebhook(context.Background(), w, payload, config)
+			return webhookDoneMsg{webhookHost(w.URL), payload.Event, err}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// testWebhooks sends a sample event to every webhook, for the command line.
+func testWebhooks(config *Config) []error {
+	payload := hookPayload{
+		Event:    webhookCompleted,
+		Id:       "test",
+		Name:     "Test",
+		Type:     "Movie",
+		Attempts: 1,
+	}
+	errs := make([]error, len(config.Webhooks))
+	for i, w := range config.Webhooks {
+		errs[i] = sendWebhook(context.Background(), w, payload, config)
+	}
+	return errs
+}